@@ -3,17 +3,49 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/fiwippi/knafeh/pkg/ffmpeg"
 )
 
-func ParseFlags() (*ffmpeg.Inputs, error) {
+// stringsFlag is a flag which can be given more than once
+type stringsFlag []string
+
+func (sf *stringsFlag) String() string {
+	return strings.Join(*sf, " ")
+}
+
+func (sf *stringsFlag) Set(s string) error {
+	*sf = append(*sf, s)
+	return nil
+}
+
+func ParseFlags() (*ffmpeg.Inputs, *options, error) {
 	// Input/Output
 	input := flag.String("i", "", "input filepath")
 	// Passes
 	singlePass := flag.Bool("sp", false, "use single pass encoding, output quality is lower but is quicker to encode")
+	streamCopy := flag.Bool("copy", false, "remuxes the input into a webm without re-encoding it, the input must be vp8/vp9/av1 with opus/vorbis audio")
+	// Poster
+	poster := flag.String("poster", "", "writes a poster jpg next to the output taken at \"HH:MM:SS.MS/HH:MM:SS/S\" or \"best\" for the best frame")
+	// Format
+	format := flag.String("format", "", "format of the output i.e. \"webm/gif/webp/avif\", if unset it's chosen from the output's extension")
+	gifFramerate := flag.Float64("giffps", 15, "framerate of gifs if \"-r\" is unset")
+	gifColors := flag.Int("colors", 256, "most colours in the gif's palette")
+	gifDither := flag.String("dither", "sierra2_4a", "how the gif is dithered i.e. \"bayer/floyd_steinberg/sierra2_4a/none\"")
+	bayerScale := flag.Int("bayerscale", 3, "scale of the bayer dithering pattern from 0 to 5, lower is more visible")
+	lossless := flag.Bool("lossless", false, "encodes webp losslessly")
+	plays := flag.Int("plays", 0, "times an animated gif/webp/avif plays, \"0\" plays it forever")
+	// DASH
+	dash := flag.String("dash", "", "encodes a ladder of webms and a dash manifest as \"height:kbps\" rungs i.e. \"720:1024,480:750\" or \"default\", implied by a \".mpd\" output")
+	segment := flag.Float64("segment", 2, "seconds between the keyframes of the dash ladder")
+	// Variants
+	var variants stringsFlag
+	flag.Var(&variants, "variant", "also encodes another output from the same decode as \"out.webm,c:v=vp8,crf=10,b:v=1000,scale=720p\", can be repeated")
 	// Metadata
 	title := flag.String("title", "", "metadata title of the video")
 	// Video
@@ -23,16 +55,73 @@ func ParseFlags() (*ffmpeg.Inputs, error) {
 	// Audio
 	audioBitrate := flag.Int("b:a", 96, "bitrate of the audio in kbps")
 	noAudio := flag.Bool("an", false, "removes audio from the video")
+	noVideo := flag.Bool("vn", false, "removes video and outputs only the audio with opus, implied by a \".opus/.ogg\" output")
+	opusApplication := flag.String("application", "", "what the opus audio is tuned for i.e. \"voip/audio/lowdelay\"")
+	opusVBR := flag.String("vbr", "", "opus bitrate mode i.e. \"on/off/constrained\"")
+	opusFrameDuration := flag.Float64("frameduration", 0, "length of each opus frame in milliseconds i.e. \"2.5/5/10/20/40/60/80/100/120\"")
+	channels := flag.String("channels", "stereo", "channels of the audio i.e. \"keep/stereo/mono\", a layout such as \"5.1\" or a pan matrix such as \"stereo|FL<FL+FC|FR<FR+FC\"")
+	// Music video
+	music := flag.String("music", "", "turns an audio input into a video showing \"cover\" art or a \"waves/spectrum/vectorscope\" visualiser")
+	cover := flag.String("cover", "", "filepath to the cover image of the music video, if unset the embedded cover art is used")
+	visSize := flag.String("vissize", "1280x720", "resolution of the music video's visualiser as \"WxH\"")
 	// Filters
 	denoise := flag.Bool("denoise", false, "denoises the video")
 	deinterlace := flag.Bool("deinterlace", false, "deinterlaces the video")
-	scale := flag.String("scale", "", "resizes the video, specified as \"width:height\"")
+	scale := flag.String("scale", "", "resizes the video, specified as \"width:height\", \"fit:WxH\", \"fill:WxH\", \"max:N\" for the longest edge, \"N%\" or \"720p\"")
+	scaler := flag.String("scaler", "lanczos", "which scaling algorithm to use i.e. \"lanczos/bicubic/spline\"")
+	pad := flag.String("pad", "", "pads the video to an aspect ratio as \"width:height\" or a resolution as \"WxH\"")
+	padColor := flag.String("padcolor", "black", "colour of the padding")
+	padBlur := flag.Bool("padblur", false, "fills the padding with a blurred copy of the video instead of a colour")
+	padPos := flag.String("padpos", "0.5:0.5", "position of the video in the padding as \"x:y\", from 0 (left/top) to 1 (right/bottom)")
+	text := flag.String("text", "", "draws a caption over the video")
+	textPos := flag.String("textpos", "bottom", "position of the caption i.e. \"top/bottom/center\" or \"x:y\" as drawtext expressions")
+	textSize := flag.Float64("textsize", 0.08, "font size of the caption relative to the height of the video")
+	textColor := flag.String("textcolor", "white", "colour of the caption")
+	textOutline := flag.Float64("textoutline", 0.06, "width of the caption's outline relative to its font size, \"0\" for no outline")
+	textOutlineColor := flag.String("textoutlinecolor", "black", "colour of the caption's outline")
+	textBox := flag.Bool("textbox", false, "draws a box behind the caption")
+	textBoxColor := flag.String("textboxcolor", "black@0.5", "colour of the box behind the caption, i.e. \"black@0.5\" for a translucent box")
+	textStart := flag.String("textss", "", "when to show the caption, accepts \"HH:MM:SS.MS/HH:MM:SS/S\"")
+	textEnd := flag.String("textto", "", "when to stop showing the caption, accepts \"HH:MM:SS.MS/HH:MM:SS/S\"")
+	captions := flag.String("captions", "", "filepath to a json list of captions")
+	font := flag.String("font", "", "filepath to the font used for captions")
+	overlay := flag.String("overlay", "", "filepath to an image or video overlaid on the video i.e. a watermark")
+	overlayPos := flag.String("overlaypos", "bottom-right", "position of the overlay i.e. \"top-left/top-right/bottom-left/bottom-right/center\" or \"x:y\" in pixels")
+	overlayMargin := flag.Int("overlaymargin", 16, "distance in pixels between the overlay and the edges of the video")
+	overlayScale := flag.Float64("overlayscale", 0, "width of the overlay relative to the width of the video, \"0\" keeps its size")
+	overlayOpacity := flag.Float64("opacity", 1, "opacity of the overlay from 0 to 1")
+	overlayStart := flag.String("overlayss", "", "when to show the overlay, accepts \"HH:MM:SS.MS/HH:MM:SS/S\"")
+	overlayEnd := flag.String("overlayto", "", "when to stop showing the overlay, accepts \"HH:MM:SS.MS/HH:MM:SS/S\"")
+	overlayLoop := flag.Bool("overlayloop", false, "loops the overlay if it's a video shorter than the output")
 	trimStart := flag.String("ss", "", "when to trim the video, accepts \"HH:MM:SS.MS/HH:MM:SS/S\"")
 	trimEnd := flag.String("to", "", "when to stop trimming the video, accepts \"HH:MM:SS.MS/HH:MM:SS/S\"")
+	fadeIn := flag.Float64("fadein", 0, "fades the video in from black and the audio in from silence over N seconds")
+	fadeOut := flag.Float64("fadeout", 0, "fades the video out to black and the audio out to silence over the last N seconds")
+	speed := flag.Float64("speed", 1, "changes the playback speed of the video, i.e. \"2\" is twice as fast and \"0.5\" is half as fast")
+	interpolate := flag.Bool("interpolate", false, "interpolates new frames when slowing the video down, this is slow to encode")
+	effect := flag.String("effect", "", "plays the video with an effect i.e. \"reverse/boomerang\", the clip is held in memory so should be short")
+	repeat := flag.Int("repeat", 1, "how many times the effect is played")
+	maxMemory := flag.Int64("maxmem", 2048, "most memory in MiB the effect can use, \"0\" means no limit")
 	dubFp := flag.String("dub", "", "filepath to the dubbed file")
 	dubLoop := flag.Bool("loop", false, "if the dubbed audio is shorter than the video or vice versa this will loop the streams to achieve the full length")
 	dubShortest := flag.Bool("shortest", false, "stops the output at the shortest video/audio stream (when dubbing)")
+	dubMix := flag.Bool("dubmix", false, "mixes the dubbed audio with the original audio instead of replacing it")
+	dubVolume := flag.Float64("dubvol", 1, "volume of the dubbed audio, i.e. \"0.5\" is half as loud")
+	sourceVolume := flag.Float64("srcvol", 1, "volume of the original audio when mixing")
+	dubDuck := flag.Bool("duck", false, "lowers the dubbed audio while the original audio is loud, i.e. music under speech")
+	dubStart := flag.String("dubss", "", "where to start reading the dubbed file, accepts \"HH:MM:SS.MS/HH:MM:SS/S\"")
+	dubEnd := flag.String("dubto", "", "where to stop reading the dubbed file, accepts \"HH:MM:SS.MS/HH:MM:SS/S\"")
+	dubDelay := flag.Float64("dubdelay", 0, "seconds into the video the dubbed audio starts")
 	crop := flag.String("crop", "", "crops the video in the format \"x:y:width:height\"")
+	rotate := flag.Int("rotate", 0, "rotates the video clockwise by \"90/180/270\" degrees")
+	hflip := flag.Bool("hflip", false, "flips the video horizontally")
+	vflip := flag.Bool("vflip", false, "flips the video vertically")
+	cuts := flag.String("cuts", "", "extracts several segments from the video, specified as \"start-end,start-end\"")
+	cutFile := flag.String("cutfile", "", "filepath to a cut list, either a csv of \"start,end\" lines or an ffmetadata file of chapters")
+	separate := flag.Bool("separate", false, "writes each segment of the cut list to its own numbered file instead of joining them")
+	crossfade := flag.Float64("xfade", 0, "length in seconds of the crossfade between joined segments of the cut list")
+	// Logging and overwriting
+	newOptions := optionFlags(flag.CommandLine)
 
 	// Validate the input and output flags exist
 	flag.Usage = func() {
@@ -48,9 +137,14 @@ func ParseFlags() (*ffmpeg.Inputs, error) {
 		os.Exit(1)
 	}
 
+	opts, err := newOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	fd, err := ffmpeg.Probe(*input)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Create the inputs
@@ -63,25 +157,73 @@ func ParseFlags() (*ffmpeg.Inputs, error) {
 	// Video args
 	err = i.ParseCodec(*codec)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	err = i.ParseCRF(*crf)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if *format != "" {
+		err = i.ParseFormat(*format)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		i.Format = ffmpeg.FormatFromExt(output)
+	}
+	i.Animation.Framerate = *gifFramerate
+	i.Animation.Colors = *gifColors
+	i.Animation.Dither = *gifDither
+	i.Animation.BayerScale = *bayerScale
+	i.Animation.Lossless = *lossless
+	i.Animation.Plays = *plays
+	i.Poster = *poster
+	if *dash == "" && strings.ToLower(filepath.Ext(output)) == ".mpd" {
+		*dash = "default"
+	}
+	if *dash != "" {
+		err = i.ParseLadder(*dash)
+		if err != nil {
+			return nil, nil, err
+		}
+		i.Dash.SegmentDuration = *segment
+		i.Dash.Framerate = fd.Framerate
 	}
 	i.Width = fd.Width
 	i.Height = fd.Height
+	i.SampleAspectRatio = fd.SampleAspectRatio
+	i.VideoDuration = fd.DurationSeconds
 
 	// Audio args
 	err = i.ParseAudioBitrate(*audioBitrate)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	i.AudioEnabled = !(*noAudio)
+	switch strings.ToLower(filepath.Ext(output)) {
+	case ".opus", ".ogg", ".oga":
+		i.AudioOnly = true
+	default:
+		i.AudioOnly = *noVideo
+	}
+	if *opusApplication != "" || *opusVBR != "" || *opusFrameDuration != 0 {
+		i.Opus = ffmpeg.NewOpusArgs()
+		i.Opus.Application = *opusApplication
+		i.Opus.VBR = *opusVBR
+		i.Opus.FrameDuration = *opusFrameDuration
+	}
 	if len(fd.AudioStreams) > 0 {
 		i.AudioTrack.Index = 0
 		i.AudioTrack.Title = fd.AudioStreams[0].Tags.Title
 	}
+	err = i.ParseChannels(*channels)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(fd.AudioStreams) > 0 {
+		i.Channels.Channels = fd.AudioStreams[0].Channels
+		i.Channels.Input = fd.AudioStreams[0].ChannelLayout
+	}
 
 	// Miscellaneous
 	i.Title = fd.Title
@@ -101,21 +243,137 @@ func ParseFlags() (*ffmpeg.Inputs, error) {
 	if *scale != "" {
 		err = i.ParseResize(*scale)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		i.Resize.Algorithm = *scaler
 	} else {
 		i.Resize = nil
 	}
+	for _, v := range variants {
+		err = i.ParseVariant(v)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if *pad != "" {
+		err = i.ParsePad(*pad)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = i.ParsePadPosition(*padPos)
+		if err != nil {
+			return nil, nil, err
+		}
+		i.Pad.Color = *padColor
+		i.Pad.Blur = *padBlur
+	}
+	if *text != "" || *captions != "" {
+		i.Captions = ffmpeg.NewCaptionFilter()
+		i.Captions.FontFile = *font
+		if *text != "" {
+			c := ffmpeg.NewCaption()
+			c.Text = *text
+			c.Position = *textPos
+			c.Size = *textSize
+			c.Color = *textColor
+			c.Outline = *textOutline
+			c.OutlineColor = *textOutlineColor
+			c.Box = *textBox
+			c.BoxColor = *textBoxColor
+			c.Start = *textStart
+			c.End = *textEnd
+			i.Captions.Captions = append(i.Captions.Captions, c)
+		}
+		if *captions != "" {
+			cs, err := ffmpeg.ReadCaptionFile(*captions)
+			if err != nil {
+				return nil, nil, err
+			}
+			i.Captions.Captions = append(i.Captions.Captions, cs...)
+		}
+	}
+	if *overlay != "" {
+		o := ffmpeg.NewOverlayFilter()
+		o.Filepath = *overlay
+		err = o.ParsePosition(*overlayPos)
+		if err != nil {
+			return nil, nil, err
+		}
+		o.Margin = *overlayMargin
+		o.Scale = *overlayScale
+		o.Opacity = *overlayOpacity
+		o.Start = *overlayStart
+		o.End = *overlayEnd
+		o.Loop = *overlayLoop
+		i.Overlays = append(i.Overlays, o)
+	}
 	i.Trim.Start = *trimStart
 	i.Trim.End = *trimEnd
+	i.Trim.VideoDuration = fd.DurationSeconds
+	if *fadeIn != 0 || *fadeOut != 0 {
+		i.Fade = ffmpeg.NewFadeFilter()
+		i.Fade.In = *fadeIn
+		i.Fade.Out = *fadeOut
+	}
+	if *speed != 1 {
+		i.Speed = ffmpeg.NewSpeedFilter()
+		i.Speed.Factor = *speed
+		i.Speed.Interpolate = *interpolate
+		i.Speed.Framerate = fd.Framerate
+		if i.Framerate > 0 {
+			i.Speed.Framerate = i.Framerate
+		}
+	}
+	if *effect != "" {
+		err = i.ParseEffect(*effect)
+		if err != nil {
+			return nil, nil, err
+		}
+		i.Effect.Repeat = *repeat
+		i.Effect.Framerate = fd.Framerate
+		i.Effect.MaxMemory = *maxMemory * 1024 * 1024
+	}
+	if *music != "" {
+		err = i.ParseMusic(*music)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = i.ParseVisualiserSize(*visSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		if *cover != "" {
+			cfd, err := ffmpeg.Probe(*cover)
+			if err != nil {
+				return nil, nil, err
+			}
+			i.Music.CoverFp = *cover
+			i.Width = cfd.Width
+			i.Height = cfd.Height
+			i.SampleAspectRatio = cfd.SampleAspectRatio
+		}
+	}
 	if *dubFp != "" {
 		dfd, err := ffmpeg.Probe(*dubFp)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		i.Dub.Filepath = *dubFp
 		i.Dub.Shortest = *dubShortest
 		i.Dub.Loop = *dubLoop
+		i.Dub.Mix = *dubMix
+		i.Dub.Volume = *dubVolume
+		i.Dub.SourceVolume = *sourceVolume
+		i.Dub.Duck = *dubDuck
+		i.Dub.Start = *dubStart
+		i.Dub.End = *dubEnd
+		i.Dub.Delay = *dubDelay
+
+		// The dubbed audio's channels are used if it replaces the audio
+		if !i.Dub.Mix && len(dfd.AudioStreams) > 0 {
+			i.Channels.Channels = dfd.AudioStreams[0].Channels
+			i.Channels.Input = dfd.AudioStreams[0].ChannelLayout
+		}
 		i.Dub.VideoDuration = fd.DurationSeconds
 		i.Dub.AudioDuration = dfd.DurationSeconds
 	} else {
@@ -124,12 +382,113 @@ func ParseFlags() (*ffmpeg.Inputs, error) {
 	if *crop != "" {
 		err = i.ParseCrop(*crop)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	} else {
 		i.Crop = nil
 	}
+	if *rotate != 0 {
+		i.Rotate = ffmpeg.NewRotateFilter()
+		i.Rotate.Degrees = *rotate
+	}
+	if *hflip || *vflip {
+		i.Flip = ffmpeg.NewFlipFilter()
+		i.Flip.Horizontal = *hflip
+		i.Flip.Vertical = *vflip
+	}
+	if *cuts != "" {
+		err = i.ParseCuts(*cuts, fd.DurationSeconds)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if *cutFile != "" {
+		i.Cuts, err = ffmpeg.ReadCutFile(*cutFile, fd.DurationSeconds)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if i.Cuts != nil {
+		i.Cuts.Separate = *separate
+		i.Cuts.Crossfade = *crossfade
+	}
 	i.TwoPass = !(*singlePass)
 
-	return i, nil
+	// Stream copying
+	if *streamCopy {
+		i.Copy = ffmpeg.NewStreamCopy()
+		if len(fd.VideoStreams) > 0 {
+			i.Copy.VideoCodec = fd.VideoStreams[0].CodecName
+		}
+		if i.AudioEnabled && len(fd.AudioStreams) > 0 {
+			i.Copy.AudioCodec = fd.AudioStreams[0].CodecName
+		}
+
+		// Trims can only be made on keyframes when copying
+		snapped, err := i.SnapTrimToKeyframes()
+		if err != nil {
+			return nil, nil, err
+		}
+		if snapped {
+			var times []string
+			if i.Trim.ValidStart() {
+				times = append(times, "-ss "+i.Trim.Start)
+			}
+			if i.Trim.ValidEnd() {
+				times = append(times, "-to "+i.Trim.End)
+			}
+			log.Printf("warning: trim snapped to the nearest keyframes: %s\n", strings.Join(times, " "))
+		}
+	}
+
+	return i, opts, nil
+}
+
+// ParseThumbsFlags parses the flags of the thumbs command
+func ParseThumbsFlags(args []string) (*ffmpeg.Thumbnails, *options, error) {
+	fs := flag.NewFlagSet("thumbs", flag.ExitOnError)
+	input := fs.String("i", "", "input filepath")
+	poster := fs.String("poster", "", "filepath of the poster image")
+	posterTime := fs.String("at", ffmpeg.BestFrame, "when the poster is taken, accepts \"HH:MM:SS.MS/HH:MM:SS/S\" or \"best\" for the best frame")
+	sheet := fs.String("sheet", "", "filepath of the contact sheet")
+	grid := fs.String("grid", "4x4", "columns and rows of the contact sheet as \"CxR\"")
+	cellWidth := fs.Int("cellwidth", 320, "width of each frame in the contact sheet")
+	font := fs.String("font", "", "filepath to the font used for the contact sheet's timestamps")
+	sprite := fs.String("sprite", "", "filepath of the sprite sheet, a webvtt track is written next to it")
+	interval := fs.Float64("interval", 5, "seconds between each frame in the sprite sheet")
+	spriteWidth := fs.Int("spritewidth", 160, "width of each frame in the sprite sheet")
+	newOptions := optionFlags(fs)
+	fs.Usage = func() {
+		fmt.Printf("Usage: ./knafeh thumbs -i in.mp4 -poster poster.jpg -sheet sheet.jpg -sprite sprite.jpg\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	opts, err := newOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fd, err := ffmpeg.Probe(*input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := ffmpeg.NewThumbnails()
+	t.InputFp = *input
+	t.Duration = fd.DurationSeconds
+	t.Width = fd.Width
+	t.Height = fd.Height
+	t.Poster = *posterTime
+	t.PosterFp = *poster
+	t.SheetFp = *sheet
+	t.CellWidth = *cellWidth
+	t.FontFile = *font
+	t.SpriteFp = *sprite
+	t.SpriteInterval = *interval
+	t.SpriteWidth = *spriteWidth
+	if _, err := fmt.Sscanf(*grid, "%dx%d", &t.Columns, &t.Rows); err != nil {
+		return nil, nil, ffmpeg.ErrThumbs
+	}
+
+	return t, opts, nil
 }
@@ -1,41 +1,113 @@
 package main
 
 import (
-	"bufio"
 	"errors"
-	"fmt"
 	"log"
 	"os"
-	"strings"
+
+	"github.com/fiwippi/knafeh/pkg/ffmpeg"
 )
 
+// version of knafeh, releases set it when they're built
+// with -ldflags "-X main.version=v1.0.0"
+var version = "dev"
+
 func main() {
-	inputs, err := ParseFlags()
+	if len(os.Args) > 1 && os.Args[1] == "thumbs" {
+		thumbs()
+		return
+	}
+
+	inputs, opts, err := ParseFlags()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// If we've managed to parse the inputs we also want
-	// to check if the user might be overwriting the file
-	// and if they're alright with it
-	if exists(inputs.OutputFp) {
-		scanner := bufio.NewScanner(os.Stdin)
-		fmt.Print("Would you like to overwrite the file? (y/N): ")
-		scanner.Scan()
-		if strings.ToLower(strings.TrimSpace(scanner.Text())) != "y" {
-			log.Fatal(errors.New("file already exists"))
-		}
+	resolved := inputs
+	cmds, err := opts.commands(func(n int) ([]*ffmpeg.Command, error) {
+		resolved = inputs.WithSuffix(n)
+		return resolved.Commands()
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	c, err := inputs.Command()
+	report := opts.newReport(resolved, inputs.InputFp)
+	finish(opts, report, run(cmds, opts, report))
+}
+
+func thumbs() {
+	t, opts, err := ParseThumbsFlags(os.Args[2:])
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = c.Run()
+	thumbs := t
+	cmds, err := opts.commands(func(n int) ([]*ffmpeg.Command, error) {
+		t = thumbs.WithSuffix(n)
+		return t.Commands()
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	report := opts.newReport(t, t.InputFp)
+	finish(opts, report, run(cmds, opts, report))
+}
+
+// run runs each command, if a report is being written then
+// each command's passes and outputs are added to it
+func run(cmds []*ffmpeg.Command, opts *options, report *ffmpeg.EncodeReport) error {
+	for _, c := range cmds {
+		var cr *ffmpeg.CommandReport
+		if report != nil {
+			cr = report.Command(c)
+			c.SetReporter(ffmpeg.MultiReporter(opts.reporter, cr))
+		} else {
+			c.SetReporter(opts.reporter)
+		}
+
+		if err := c.Run(); err != nil {
+			return err
+		}
+		if cr != nil {
+			cr.AddOutputs(c.Outputs())
+		}
+		if opts.verify {
+			if err := c.Verify(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// finish writes the report, which is also written when the
+// encode fails, then exits if the encode failed
+func finish(opts *options, report *ffmpeg.EncodeReport, err error) {
+	if report != nil && err != nil {
+		report.Error = err.Error()
+	}
+	if rerr := opts.writeReport(report); rerr != nil {
+		log.Printf("writing the report: %v\n", rerr)
+		if err == nil {
+			os.Exit(1)
+		}
+	}
+
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// fatal exits with the error, if ffmpeg failed then
+// the command it was run with is shown before it
+func fatal(err error) {
+	var ee *ffmpeg.ExecError
+	if errors.As(err, &ee) {
+		log.Printf("command: %s\n", ee.Command())
+	}
+	log.Fatal(err)
 }
 
 func exists(fp string) bool {
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fiwippi/knafeh/pkg/ffmpeg"
+)
+
+// overwritePolicy decides what happens when an output already exists
+type overwritePolicy int
+
+const (
+	askOverwrite    overwritePolicy = iota // Ask on a terminal, otherwise refuse
+	alwaysOverwrite                        // Overwrite the output
+	neverOverwrite                         // Refuse to overwrite the output
+	suffixOutput                           // Number the output so it doesn't overwrite anything
+)
+
+// options control how knafeh runs rather than what it encodes
+type options struct {
+	reporter  ffmpeg.Reporter
+	overwrite overwritePolicy
+	verify    bool
+
+	// Where the report of the encode is written, "" if
+	// it isn't, and whether it's added to an existing one
+	reportFp     string
+	reportAppend bool
+}
+
+// optionFlags adds the flags of the options, the returned
+// func creates the options once the flags have been parsed
+func optionFlags(fs *flag.FlagSet) func() (*options, error) {
+	level := fs.String("loglevel", "info", "least important messages which are shown i.e. \"debug/info/warning/error\"")
+	jsonLog := fs.Bool("json", false, "logs each message as a line of json")
+	yes := fs.Bool("y", false, "overwrites outputs which already exist without asking")
+	no := fs.Bool("n", false, "exits instead of overwriting outputs which already exist")
+	suffix := fs.Bool("suffix", false, "numbers the outputs i.e. \"out (1).webm\" instead of overwriting existing files")
+	verify := fs.Bool("verify", true, "probes and decodes each output once it's encoded to check it's what was asked for, \"-verify=false\" skips this")
+	report := fs.String("report", "", "writes a json report of the encode to the filepath i.e. \"out.json\"")
+	reportAppend := fs.Bool("reportappend", false, "adds the encode to the report if it already exists, so a batch of encodes can share one report")
+
+	return func() (*options, error) {
+		o := &options{overwrite: askOverwrite, verify: *verify, reportFp: *report, reportAppend: *reportAppend}
+		if o.reportAppend && o.reportFp == "" {
+			return nil, errors.New("-reportappend needs a report to add to, use -report")
+		}
+
+		min, err := ffmpeg.ParseLevel(*level)
+		if err != nil {
+			return nil, err
+		}
+		if *jsonLog {
+			o.reporter = ffmpeg.NewJSONReporter(os.Stderr, min)
+		} else {
+			o.reporter = &cliReporter{w: os.Stderr, min: min}
+		}
+
+		switch {
+		case *yes && !*no && !*suffix:
+			o.overwrite = alwaysOverwrite
+		case *no && !*yes && !*suffix:
+			o.overwrite = neverOverwrite
+		case *suffix && !*yes && !*no:
+			o.overwrite = suffixOutput
+		case *yes || *no || *suffix:
+			return nil, errors.New("only one of -y, -n and -suffix can be used")
+		}
+
+		return o, nil
+	}
+}
+
+// commands builds the commands then checks the outputs which already
+// exist against the overwrite policy, when suffixing the outputs are
+// numbered until none of them exist
+func (o *options) commands(build func(n int) ([]*ffmpeg.Command, error)) ([]*ffmpeg.Command, error) {
+	for n := 0; ; n++ {
+		cmds, err := build(n)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cmds {
+			if err := c.ValidOutputs(); err != nil {
+				return nil, err
+			}
+		}
+
+		existing := existingOutputs(cmds)
+		if len(existing) == 0 {
+			return cmds, nil
+		}
+
+		switch o.overwrite {
+		case alwaysOverwrite:
+			return cmds, nil
+		case neverOverwrite:
+			return nil, fmt.Errorf("%s already exists", existing[0])
+		case suffixOutput:
+			continue
+		}
+
+		// Scripts can't answer the prompt so they
+		// must choose a policy instead
+		if !interactive() {
+			return nil, fmt.Errorf("%s already exists, use -y to overwrite it, -n to keep it or -suffix to number the output", existing[0])
+		}
+		scanner := bufio.NewScanner(os.Stdin)
+		for _, fp := range existing {
+			fmt.Printf("Would you like to overwrite %s? (y/N): ", fp)
+			scanner.Scan()
+			if strings.ToLower(strings.TrimSpace(scanner.Text())) != "y" {
+				return nil, errors.New("file already exists")
+			}
+		}
+		return cmds, nil
+	}
+}
+
+// existingOutputs returns the outputs of the commands which already exist
+func existingOutputs(cmds []*ffmpeg.Command) []string {
+	existing := make([]string, 0)
+	for _, c := range cmds {
+		for _, fp := range c.Outputs() {
+			if exists(fp) {
+				existing = append(existing, fp)
+			}
+		}
+	}
+	return existing
+}
+
+// interactive returns whether stdin is a terminal which can answer
+// prompts, the null device is also a character device so it's ruled out
+func interactive() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(fi, null) {
+		return false
+	}
+	return true
+}
+
+// newReport returns the report of the encode of the input with the
+// settings, nil if no report is written
+func (o *options) newReport(settings interface{}, inputFp string) *ffmpeg.EncodeReport {
+	if o.reportFp == "" {
+		return nil
+	}
+	return ffmpeg.NewEncodeReport(version, settings, inputFp)
+}
+
+// writeReport writes the report of the encode, when appending it's
+// added to the encodes of the report which already exists
+func (o *options) writeReport(er *ffmpeg.EncodeReport) error {
+	if er == nil {
+		return nil
+	}
+
+	r := &ffmpeg.Report{Encodes: make([]*ffmpeg.EncodeReport, 0)}
+	if o.reportAppend && exists(o.reportFp) {
+		var err error
+		r, err = ffmpeg.ReadReport(o.reportFp)
+		if err != nil {
+			return err
+		}
+	}
+	r.Encodes = append(r.Encodes, er)
+
+	return r.WriteFile(o.reportFp)
+}
@@ -0,0 +1,168 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Caption is a piece of text drawn over the video
+type Caption struct {
+	Text         string  `json:"text"`
+	Position     string  `json:"position"`      // Either "top", "bottom", "center" or "x:y" expressions for drawtext
+	Size         float64 `json:"size"`          // Font size relative to the height of the video
+	Color        string  `json:"color"`         // Colour of the text
+	Outline      float64 `json:"outline"`       // Width of the outline relative to the font size, 0 for no outline
+	OutlineColor string  `json:"outline_color"` // Colour of the outline
+	Box          bool    `json:"box"`           // Draw a box behind the text
+	BoxColor     string  `json:"box_color"`     // Colour of the box, i.e. "black@0.5" for a translucent box
+	Start        string  `json:"start"`         // When the caption appears in the output, accepts "HH:MM:SS.MS/HH:MM:SS/S"
+	End          string  `json:"end"`           // When the caption disappears, empty to show it until the end
+}
+
+// NewCaption returns a caption in the top/bottom meme style,
+// i.e. white text with a black outline
+func NewCaption() *Caption {
+	return &Caption{
+		Text:         "",
+		Position:     "bottom",
+		Size:         0.08,
+		Color:        "white",
+		Outline:      0.06,
+		OutlineColor: "black",
+		Box:          false,
+		BoxColor:     "black@0.5",
+		Start:        "",
+		End:          "",
+	}
+}
+
+func (c *Caption) Valid() bool {
+	if c.Text == "" || c.Size <= 0 || c.Outline < 0 {
+		return false
+	}
+	if _, _, ok := c.coordinates(); !ok {
+		return false
+	}
+	return validWindow(c.Start, c.End)
+}
+
+// coordinates returns the drawtext expressions for where the caption is drawn
+func (c *Caption) coordinates() (string, string, bool) {
+	const centreX = "(w-text_w)/2"
+
+	switch strings.ToLower(c.Position) {
+	case "top":
+		return centreX, "h*0.05", true
+	case "bottom", "":
+		return centreX, "h-text_h-h*0.05", true
+	case "center", "centre":
+		return centreX, "(h-text_h)/2", true
+	}
+
+	parts := strings.SplitN(c.Position, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// CaptionFilter draws captions over the video
+type CaptionFilter struct {
+	FontFile string // Filepath to the font, if empty then fontconfig picks the default font
+	Captions []*Caption
+}
+
+func NewCaptionFilter() *CaptionFilter {
+	return &CaptionFilter{
+		FontFile: "",
+		Captions: make([]*Caption, 0),
+	}
+}
+
+func (cf *CaptionFilter) Valid() bool {
+	if len(cf.Captions) == 0 {
+		return false
+	}
+	for _, c := range cf.Captions {
+		if !c.Valid() {
+			return false
+		}
+	}
+	return true
+}
+
+// Args returns a drawtext filter for each caption, height is the height of
+// the video in pixels so the font size can be worked out, if it's unknown
+// then the font size is left as an expression for drawtext to evaluate
+func (cf *CaptionFilter) Args(height int) [][]string {
+	args := make([][]string, 0, len(cf.Captions))
+
+	for _, c := range cf.Captions {
+		x, y, _ := c.coordinates()
+
+		var size, border string
+		if height > 0 {
+			px := math.Max(1, math.Round(float64(height)*c.Size))
+			size = strconv.Itoa(int(px))
+			border = strconv.Itoa(int(math.Round(px * c.Outline)))
+		} else {
+			size = fmt.Sprintf("h*%s", strconv.FormatFloat(c.Size, 'f', -1, 64))
+			border = "1"
+		}
+
+		// Expansion is disabled so the text is drawn exactly as it's written
+		opts := []string{
+			"text=" + escapeFilterArg(c.Text),
+			"expansion=none",
+			"fontsize=" + escapeFilterArg(size),
+			"fontcolor=" + escapeFilterArg(c.Color),
+			"x=" + escapeFilterArg(x),
+			"y=" + escapeFilterArg(y),
+		}
+		if cf.FontFile != "" {
+			opts = append(opts, "fontfile="+escapeFilterArg(cf.FontFile))
+		}
+		if c.Outline > 0 {
+			opts = append(opts, "borderw="+border, "bordercolor="+escapeFilterArg(c.OutlineColor))
+		}
+		if c.Box {
+			opts = append(opts, "box=1", "boxcolor="+escapeFilterArg(c.BoxColor), "boxborderw="+border)
+		}
+		if enable := enableWindow(c.Start, c.End); enable != "" {
+			opts = append(opts, "enable="+escapeFilterArg(enable))
+		}
+
+		args = append(args, []string{"drawtext", strings.Join(opts, ":")})
+	}
+
+	return args
+}
+
+// ReadCaptionFile reads captions from a JSON file, it contains a list
+// of captions which are each merged over the default caption style
+func ReadCaptionFile(fp string) ([]*Caption, error) {
+	data, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	captions := make([]*Caption, 0, len(raw))
+	for _, r := range raw {
+		c := NewCaption()
+		if err := json.Unmarshal(r, c); err != nil {
+			return nil, err
+		}
+		captions = append(captions, c)
+	}
+
+	return captions, nil
+}
@@ -0,0 +1,221 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ChannelMode decides how many audio channels the output has
+type ChannelMode int
+
+const (
+	Stereo       ChannelMode = iota // Downmix to stereo, mono is left as mono
+	KeepChannels                    // Keep the channels of the input, i.e. 5.1 stays 5.1
+	Mono                            // Downmix to mono
+	CustomLayout                    // Convert to the channel layout in Layout
+	CustomMix                       // Mix the channels with the pan filter in Matrix
+)
+
+// Most channels libopus and libvorbis can encode
+const maxChannels = 8
+
+// Downmixes from surround to stereo which keep the centre and
+// surround channels audible, unlike the default downmix. The
+// gains are renormalised by pan ("<") so the mix doesn't clip
+var stereoDownmixes = map[string]string{
+	"5.0":       "stereo|FL<FL+0.707*FC+0.707*BL|FR<FR+0.707*FC+0.707*BR",
+	"5.1":       "stereo|FL<FL+0.707*FC+0.707*BL|FR<FR+0.707*FC+0.707*BR",
+	"5.0(side)": "stereo|FL<FL+0.707*FC+0.707*SL|FR<FR+0.707*FC+0.707*SR",
+	"5.1(side)": "stereo|FL<FL+0.707*FC+0.707*SL|FR<FR+0.707*FC+0.707*SR",
+	"7.1":       "stereo|FL<FL+0.707*FC+0.5*BL+0.5*SL|FR<FR+0.707*FC+0.5*BR+0.5*SR",
+}
+
+// Channels in each of the layouts which can be chosen
+var layoutChannels = map[string]int{
+	"mono":   1,
+	"stereo": 2,
+	"2.1":    3,
+	"3.0":    3,
+	"quad":   4,
+	"4.0":    4,
+	"5.0":    5,
+	"5.1":    6,
+	"6.1":    7,
+	"7.1":    8,
+}
+
+// ChannelFilter converts the audio to the channels of the output.
+// The channels of the input are needed so surround sound is
+// downmixed properly and mono isn't needlessly upmixed
+type ChannelFilter struct {
+	Mode     ChannelMode
+	Layout   string // Layout of the output when using CustomLayout i.e. "5.1"
+	Matrix   string // Pan filter args when using CustomMix i.e. "stereo|FL<FL+FC|FR<FR+FC"
+	Channels int    // Channels in the input's audio
+	Input    string // Channel layout of the input's audio i.e. "5.1(side)"
+}
+
+func NewChannelFilter() *ChannelFilter {
+	return &ChannelFilter{
+		Mode:     Stereo,
+		Layout:   "",
+		Matrix:   "",
+		Channels: -1,
+		Input:    "",
+	}
+}
+
+func (cf *ChannelFilter) Valid() bool {
+	switch cf.Mode {
+	case CustomLayout:
+		_, ok := layoutChannels[cf.Layout]
+		return ok
+	case CustomMix:
+		return validMatrix(cf.Matrix) && cf.OutputChannels() > 0 && cf.OutputChannels() <= maxChannels
+	}
+	return true
+}
+
+// validMatrix returns whether the matrix follows the pan filter's
+// syntax "layout|out=gain*in+gain*in|...", so nothing else can be
+// passed into the filtergraph with it
+func validMatrix(matrix string) bool {
+	defs := strings.Split(matrix, "|")
+	if len(defs) < 2 {
+		return false
+	}
+
+	for _, def := range defs[1:] {
+		n := strings.IndexAny(def, "=<")
+		if n < 0 || !validChannelName(strings.TrimSpace(def[:n])) {
+			return false
+		}
+
+		// Each input channel is added or subtracted with an optional gain
+		terms := strings.Split(strings.ReplaceAll(def[n+1:], "-", "+"), "+")
+		for j, term := range terms {
+			term = strings.TrimSpace(term)
+			// The first channel may be subtracted i.e. "FL=-FR"
+			if j == 0 && term == "" && len(terms) > 1 {
+				continue
+			}
+			if k := strings.Index(term, "*"); k >= 0 {
+				if _, err := strconv.ParseFloat(strings.TrimSpace(term[:k]), 64); err != nil {
+					return false
+				}
+				term = strings.TrimSpace(term[k+1:])
+			}
+			if !validChannelName(term) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// validChannelName returns whether the name could be a channel
+// such as "FL" or "c0", it's checked by ffmpeg once it's parsed
+func validChannelName(name string) bool {
+	if name == "" || !unicode.IsLetter(rune(name[0])) {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// OutputChannels returns how many channels the output has
+func (cf *ChannelFilter) OutputChannels() int {
+	switch cf.Mode {
+	case Mono:
+		return 1
+	case CustomLayout:
+		return layoutChannels[cf.Layout]
+	case CustomMix:
+		layout := strings.Split(cf.Matrix, "|")[0]
+		if n, ok := layoutChannels[layout]; ok {
+			return n
+		}
+		if strings.HasSuffix(layout, "c") {
+			if n, err := strconv.Atoi(strings.TrimSuffix(layout, "c")); err == nil {
+				return n
+			}
+		}
+		return -1
+	case KeepChannels:
+		if cf.Channels > 0 && cf.Channels <= maxChannels {
+			return cf.Channels
+		}
+	}
+
+	// Stereo, or when the input's channels aren't known
+	if cf.Channels == 1 {
+		return 1
+	}
+	return 2
+}
+
+// normalisedLayout returns the input's layout without the variant,
+// the encoders expect i.e. "5.1" rather than "5.1(side)"
+func (cf *ChannelFilter) normalisedLayout() string {
+	layout := cf.Input
+	if n := strings.Index(layout, "("); n > 0 {
+		layout = layout[:n]
+	}
+	if layoutChannels[layout] != cf.Channels {
+		return ""
+	}
+	return layout
+}
+
+// Args returns the filters which convert the audio to the output's
+// channels, if there are none then ArgChannels should be used instead
+func (cf *ChannelFilter) Args() [][]string {
+	switch cf.Mode {
+	case CustomMix:
+		return [][]string{{"pan", cf.Matrix}}
+	case CustomLayout:
+		return [][]string{{"aformat", "channel_layouts=" + cf.Layout}}
+	case KeepChannels:
+		if cf.OutputChannels() > 2 {
+			if layout := cf.normalisedLayout(); layout != "" {
+				return [][]string{{"aformat", "channel_layouts=" + layout}}
+			}
+		}
+	case Stereo:
+		if matrix, ok := stereoDownmixes[cf.Input]; ok {
+			return [][]string{{"pan", matrix}}
+		}
+	}
+	return nil
+}
+
+// ArgChannels returns the option which sets the output's channels
+func (cf *ChannelFilter) ArgChannels() (string, string) {
+	return "-ac", fmt.Sprint(cf.OutputChannels())
+}
+
+// OpusArgs returns the options libopus needs for the channels,
+// surround sound needs the Vorbis channel mapping family
+func (cf *ChannelFilter) OpusArgs() [][]string {
+	if cf.OutputChannels() > 2 {
+		return [][]string{{"-mapping_family", "1"}}
+	}
+	return nil
+}
+
+// OpusBitrate scales a bitrate meant for stereo by the output's channels
+// so surround gets enough bits for each channel and mono isn't wasteful
+func (cf *ChannelFilter) OpusBitrate(stereo int) int {
+	b := stereo * cf.OutputChannels() / 2
+	if min := 6 * cf.OutputChannels(); b < min {
+		return min
+	}
+	return b
+}
@@ -77,6 +77,16 @@ func (c Codec) ArgVideoCodecSpecific() [][]string {
 	return args
 }
 
+// ArgStillImage returns the options which suit encoding a single
+// image shown for the whole video, VP9's screen content mode spends
+// far fewer bits on frames which haven't changed
+func (c Codec) ArgStillImage() [][]string {
+	if c == VP9 {
+		return [][]string{{"-tune-content", "screen"}}
+	}
+	return [][]string{}
+}
+
 func (c Codec) ArgSlices(slices, width, height, threads int) [][]string {
 	// For VP8 `-slices` converts tp `--token-parts` in the libvpx encoder
 	// For VP9 we just specify `-tile-columns` instead, it is more efficient than using `-row-columns`
@@ -6,49 +6,80 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	orderedmap "github.com/wk8/go-ordered-map"
 )
 
+// input is a file ffmpeg reads from
+type input struct {
+	fp   string
+	args *orderedmap.OrderedMap // Options placed before the input's "-i"
+}
+
 type Command struct {
 	// Order or args joined together is as specified by
 	// the numbers
-	inputArgs          *orderedmap.OrderedMap // #1
-	videoCodecArgs     *orderedmap.OrderedMap // #2
-	videoFilterArgs    *orderedmap.OrderedMap // #3
-	audioCodecArgs     *orderedmap.OrderedMap // #4
-	dubAudioFilterArgs *orderedmap.OrderedMap // #5
-	mapArgs            *orderedmap.OrderedMap // #6
-	generalArgs        *orderedmap.OrderedMap // #7
-
-	audioFilterInput int // Input the audio filter should affect
-
-	// fp of the input and output
-	twoPass bool
-	inputFp string
+	inputs         []*input               // #1, the first input is the main input
+	videoCodecArgs *orderedmap.OrderedMap // #2
+	filterChains   []filterChain          // #3
+	audioCodecArgs *orderedmap.OrderedMap // #4
+	video, audio   *filterStream          // #5, streams which are mapped into the output
+	maps           []string               // #5, streams which are mapped as they are
+	outputs        []*Command             // Further outputs encoded from the same filtergraph
+	sidecars       []sidecar              // Files written next to the output once it's encoded
+	generalArgs    *orderedmap.OrderedMap // #6
+
+	// Where the command reports what it's doing
+	// and the expected duration of the output
+	reporter Reporter
+	duration time.Duration
+
+	// What the output should contain once it's encoded,
+	// nil if the output isn't verified
+	expect *Expectation
+
+	// Count of labels used in the filtergraph
+	labels int
+
+	// fp of the output
+	twoPass  bool
 	outputFp string
+	writeFp  string // Temporary file the output is written to, it's renamed to outputFp once encoded
 }
 
 // Private
 func newCommand() *Command {
 	return &Command{
-		generalArgs:        orderedmap.New(),
-		inputArgs:          orderedmap.New(),
-		videoCodecArgs:     orderedmap.New(),
-		audioCodecArgs:     orderedmap.New(),
-		videoFilterArgs:    orderedmap.New(),
-		dubAudioFilterArgs: orderedmap.New(),
-		mapArgs:            orderedmap.New(),
+		generalArgs:    orderedmap.New(),
+		inputs:         make([]*input, 0),
+		videoCodecArgs: orderedmap.New(),
+		audioCodecArgs: orderedmap.New(),
+		filterChains:   make([]filterChain, 0),
+		maps:           make([]string, 0),
+		outputs:        make([]*Command, 0),
+		sidecars:       make([]sidecar, 0),
+		reporter:       NopReporter,
+		duration:       -1,
 	}
 }
 
-func (c *Command) addInputArgs(arg string) {
-	c.inputArgs.Set(fmt.Sprintf("\"%s\"", arg), true)
+// addInput adds a file for ffmpeg to read from and returns its index,
+// which is how its streams are referred to i.e. "1:a"
+func (c *Command) addInput(fp string) int {
+	c.inputs = append(c.inputs, &input{fp: fp, args: orderedmap.New()})
+	return len(c.inputs) - 1
+}
+
+func (c *Command) addInputArg(index int, k, v string) {
+	c.inputs[index].args.Set(k, v)
 }
 
-func (c *Command) addMapArgs(arg string) {
-	c.mapArgs.Set(arg, true)
+// addInputSeekArg adds an option to the main input
+func (c *Command) addInputSeekArg(k, v string) {
+	c.addInputArg(0, k, v)
 }
 
 func (c *Command) addGeneralArg(k, v string) {
@@ -66,68 +97,208 @@ func (c *Command) addVideoArgs(args [][]string) {
 
 }
 
+func (c *Command) addAudioArg(k, v string) {
+	c.audioCodecArgs.Set(k, v)
+}
+
+func (c *Command) addAudioArgs(args [][]string) {
+	for _, pair := range args {
+		c.audioCodecArgs.Set(pair[0], pair[1])
+	}
+}
+
+// addMap maps a stream into the output without filtering it, e.g. "1"
+func (c *Command) addMap(spec string) {
+	c.maps = append(c.maps, spec)
+}
+
+// setVideoSource sets the input stream which is filtered and
+// mapped as the video, e.g. "0:v:0"
+func (c *Command) setVideoSource(spec string) {
+	c.video = newFilterStream(spec)
+}
+
+// setAudioSource sets the input stream which is filtered and
+// mapped as the audio, e.g. "0:a:0"
+func (c *Command) setAudioSource(spec string) {
+	c.audio = newFilterStream(spec)
+}
+
+func (c *Command) hasVideo() bool {
+	return c.video != nil
+}
+
+func (c *Command) hasAudio() bool {
+	return c.audio != nil
+}
+
 func (c *Command) addVideoFilterArg(k, v string) {
-	c.videoFilterArgs.Set(k, v)
+	if c.hasVideo() {
+		c.video.filters = append(c.video.filters, filter{name: k, args: v})
+	}
 }
 
 func (c *Command) addAudioFilterArg(k, v string) {
-	c.dubAudioFilterArgs.Set(k, v)
+	if c.hasAudio() {
+		c.audio.filters = append(c.audio.filters, filter{name: k, args: v})
+	}
 }
 
-func (c *Command) addAudioArg(k, v string) {
-	c.audioCodecArgs.Set(k, v)
+func (c *Command) addFilterChain(inputs []string, filters []filter, outputs []string) {
+	c.filterChains = append(c.filterChains, filterChain{
+		inputs:  inputs,
+		filters: filters,
+		outputs: outputs,
+	})
 }
 
-func (c *Command) VideoFiltersString() string {
-	var str string
-
-	// #3
-	if c.videoFilterArgs.Len() > 0 {
-		var filters string
-		for pair := c.videoFilterArgs.Oldest(); pair != nil; pair = pair.Next() {
-			filters += fmt.Sprintf(",%s=%s", pair.Key, pair.Value)
+// splitStream splits a stream so it can be mapped into n outputs, a
+// stream read straight from an input can be mapped more than once
+// so it's left as it is
+func (c *Command) splitStream(fs *filterStream, name, prefix string, n int) []*filterStream {
+	streams := make([]*filterStream, 0, n)
+	if fs == nil || !fs.filtered() {
+		for j := 0; j < n; j++ {
+			if fs == nil {
+				streams = append(streams, nil)
+			} else {
+				streams = append(streams, newFilterStream(fs.label))
+			}
 		}
-		str = fmt.Sprintf("[0:v]%s", strings.TrimPrefix(filters, ","))
+		return streams
 	}
 
-	return str
+	in := c.closeStream(fs, prefix)
+	outs := make([]string, 0, n)
+	for j := 0; j < n; j++ {
+		outs = append(outs, c.newLabel(prefix+"s"))
+		streams = append(streams, newFilterStream(outs[j]))
+	}
+	c.addFilterChain([]string{in}, []filter{{name, strconv.Itoa(n)}}, outs)
+
+	return streams
+}
+
+// newLabel returns a filtergraph label which hasn't been used yet
+func (c *Command) newLabel(prefix string) string {
+	c.labels += 1
+	return fmt.Sprintf("%s%d", prefix, c.labels)
+}
+
+// closeVideo closes the filters queued on the video into
+// a chain and returns the label of the chain's output
+func (c *Command) closeVideo() string {
+	return c.closeStream(c.video, "v")
+}
+
+// closeAudio closes the filters queued on the audio into
+// a chain and returns the label of the chain's output
+func (c *Command) closeAudio() string {
+	return c.closeStream(c.audio, "a")
 }
 
+func (c *Command) closeStream(fs *filterStream, prefix string) string {
+	if len(fs.filters) > 0 {
+		out := c.newLabel(prefix)
+		c.addFilterChain([]string{fs.label}, fs.filters, []string{out})
+		fs.label = out
+		fs.filters = make([]filter, 0)
+	}
+	return fs.label
+}
+
+// mapsAudio returns whether the audio is mapped into the output, on the
+// first pass the audio is only needed if it's part of the filtergraph
+func (c *Command) mapsAudio(firstPass bool) bool {
+	if !c.hasAudio() {
+		return false
+	}
+	return !firstPass || c.audio.filtered()
+}
+
+func (c *Command) FiltersString() string {
+	return c.filtersString(false)
+}
+
+// VideoFiltersString returns the filtergraph the video is filtered with
+//
+// Deprecated: the video and audio are filtered in one graph, use FiltersString
+func (c *Command) VideoFiltersString() string {
+	return c.FiltersString()
+}
+
+// AudioFiltersString returns the filtergraph the audio is filtered with
+//
+// Deprecated: the video and audio are filtered in one graph, use FiltersString
 func (c *Command) AudioFiltersString() string {
-	var str string
+	return c.FiltersString()
+}
 
-	// #5
-	if c.dubAudioFilterArgs.Len() > 0 {
-		var filters string
-		for pair := c.dubAudioFilterArgs.Oldest(); pair != nil; pair = pair.Next() {
-			filters += fmt.Sprintf(",%s=%s", pair.Key, pair.Value)
-		}
-		str = fmt.Sprintf("[%d:a]%s", c.audioFilterInput, strings.TrimPrefix(filters, ","))
+func (c *Command) filtersString(firstPass bool) string {
+	chains := make([]string, 0)
+
+	for _, fc := range c.filterChains {
+		chains = append(chains, fc.String(firstPass))
 	}
 
-	return str
+	// Filters which are still queued on the streams are
+	// closed into chains which output to the final labels
+	if c.hasVideo() && len(c.video.filters) > 0 {
+		fc := filterChain{inputs: []string{c.video.label}, filters: c.video.filters, outputs: []string{"vout"}}
+		chains = append(chains, fc.String(firstPass))
+	}
+	if c.mapsAudio(firstPass) && len(c.audio.filters) > 0 {
+		fc := filterChain{inputs: []string{c.audio.label}, filters: c.audio.filters, outputs: []string{"aout"}}
+		chains = append(chains, fc.String(firstPass))
+	}
+
+	return strings.Join(chains, ";")
 }
 
+// StringSlice returns the args of the command without its output, if
+// it has variants then the output is included to separate it from the
+// variants' args which each end with their own output
 func (c *Command) StringSlice() []string {
-	str := make([]string, 0)
+	args := append(c.inputArgs(), c.args(false)...)
+	if len(c.outputs) == 0 {
+		return args
+	}
 
-	// #1
-	for pair := c.inputArgs.Oldest(); pair != nil; pair = pair.Next() {
-		str = append(str, "-i")
-		str = append(str, fmt.Sprintf("%s", pair.Key))
+	args = append(args, c.outputFp)
+	for _, o := range c.outputs {
+		args = append(args, o.variantArgs(false)...)
+		args = append(args, o.outputFp)
 	}
+	return args
+}
 
+func (c *Command) args(firstPass bool) []string {
 	// #2
+	str := c.videoArgs()
+
+	// #3
+	if filters := c.filtersString(firstPass); filters != "" {
+		str = append(str, "-filter_complex")
+		str = append(str, filters)
+	}
+
+	return append(str, c.outputArgs(firstPass)...)
+}
+
+// videoArgs returns #2, the options of the video's encoder
+func (c *Command) videoArgs() []string {
+	str := make([]string, 0)
 	for pair := c.videoCodecArgs.Oldest(); pair != nil; pair = pair.Next() {
 		str = append(str, fmt.Sprintf("%s", pair.Key))
 		str = append(str, fmt.Sprintf("%s", pair.Value))
 	}
+	return str
+}
 
-	// #3
-	if c.videoFilterArgs.Len() > 0 {
-		str = append(str, "-filter_complex")
-		str = append(str, fmt.Sprintf("%s", c.VideoFiltersString()))
-	}
+// outputArgs returns #4 to #6, the options of the audio's
+// encoder, the streams which are mapped and the output
+func (c *Command) outputArgs(firstPass bool) []string {
+	str := make([]string, 0)
 
 	// #4
 	for pair := c.audioCodecArgs.Oldest(); pair != nil; pair = pair.Next() {
@@ -136,19 +307,20 @@ func (c *Command) StringSlice() []string {
 	}
 
 	// #5
-	if c.dubAudioFilterArgs.Len() > 0 {
-		fmt.Println(c.dubAudioFilterArgs.Oldest())
-		str = append(str, "-filter_complex")
-		str = append(str, fmt.Sprintf("%s", c.AudioFiltersString()))
+	if c.hasVideo() {
+		str = append(str, "-map")
+		str = append(str, c.video.mapArg("vout"))
 	}
-
-	// #6
-	for pair := c.mapArgs.Oldest(); pair != nil; pair = pair.Next() {
+	if c.mapsAudio(firstPass) {
 		str = append(str, "-map")
-		str = append(str, fmt.Sprintf("%s", pair.Key))
+		str = append(str, c.audio.mapArg("aout"))
+	}
+	for _, spec := range c.maps {
+		str = append(str, "-map")
+		str = append(str, spec)
 	}
 
-	// #7
+	// #6
 	for pair := c.generalArgs.Oldest(); pair != nil; pair = pair.Next() {
 		if pair.Value == "" {
 			str = append(str, fmt.Sprintf("%s", pair.Key))
@@ -162,54 +334,89 @@ func (c *Command) StringSlice() []string {
 	return str
 }
 
+// variantArgs returns the args of an output which is encoded
+// alongside the main output, its filters are in the main
+// output's filtergraph so only its encoders and maps are needed
+func (c *Command) variantArgs(firstPass bool) []string {
+	return append(c.videoArgs(), c.outputArgs(firstPass)...)
+}
+
 func (c *Command) String() string {
 	return strings.Join(c.StringSlice(), " ")
 }
 
+// Output returns the filepath the command writes to
+func (c *Command) Output() string {
+	return c.outputFp
+}
+
+// Outputs returns the filepaths of the output, every variant and
+// the files which are written next to the output
+func (c *Command) Outputs() []string {
+	fps := []string{c.outputFp}
+	for _, o := range c.outputs {
+		fps = append(fps, o.outputFp)
+	}
+	for _, s := range c.sidecars {
+		fps = append(fps, s.fp)
+	}
+	return fps
+}
+
+func (c *Command) inputArgs() []string {
+	// #1
+	args := make([]string, 0)
+	for _, in := range c.inputs {
+		for pair := in.args.Oldest(); pair != nil; pair = pair.Next() {
+			args = append(args, fmt.Sprintf("%s", pair.Key))
+			args = append(args, fmt.Sprintf("%s", pair.Value))
+		}
+		args = append(args, "-i")
+		args = append(args, in.fp)
+	}
+
+	return args
+}
+
 func (c *Command) firstPassArgs(passlogfp string) []string {
 	// Setup first pass
 	args := make([]string, 0)
-	core := c.StringSlice()
 
 	if c.twoPass {
-		// Looping filters cause infinite loops on the first pass so we remove them and
-		// replace them with null equivalents which simply pass through the stream
-		dubbing := false
-		for i, v := range core {
-			if v == "loop=-1:32767:0" {
-				core[i] = "null"
-			}
-			if v == "aloop=-1:2147483647:0" {
-				core[i] = "anull"
-			}
-			if strings.Contains(v, ":a") {
-				dubbing = true
-			}
-		}
-
-		args = append(args, "-i")
-		args = append(args, c.inputFp)
-		if !dubbing { // If we're not dubbing audio
+		args = append(args, c.inputArgs()...)
+		if !c.mapsAudio(true) { // If the audio isn't needed
 			args = append(args, "-an")
 		}
 
-		args = append(args, core...)
+		args = append(args, c.args(true)...)
 		args = append(args, "-y")
 		args = append(args, "-pass")
 		args = append(args, "1")
 		args = append(args, "-passlogfile")
 		args = append(args, passlogfp)
-		if runtime.GOOS == "windows" {
-			args = append(args, "NUL")
-		} else {
-			args = append(args, "/dev/null")
+		args = append(args, nullOutput())
+
+		// Each variant needs its own log of the first pass
+		for n, o := range c.outputs {
+			if !o.mapsAudio(true) {
+				args = append(args, "-an")
+			}
+			args = append(args, o.variantArgs(true)...)
+			args = append(args, "-pass")
+			args = append(args, "1")
+			args = append(args, "-passlogfile")
+			args = append(args, variantPasslogfp(passlogfp, n))
+			args = append(args, nullOutput())
 		}
 	} else {
-		args = append(args, "-i")
-		args = append(args, c.inputFp)
-		args = append(args, core...)
+		args = append(args, c.inputArgs()...)
+		args = append(args, c.args(false)...)
 		args = append(args, "-y")
-		args = append(args, c.outputFp)
+		args = append(args, c.target())
+		for _, o := range c.outputs {
+			args = append(args, o.variantArgs(false)...)
+			args = append(args, o.target())
+		}
 	}
 
 	return args
@@ -218,24 +425,75 @@ func (c *Command) firstPassArgs(passlogfp string) []string {
 func (c *Command) secondPassArgs(passlogfp string) []string {
 	// Setup first pass
 	args := make([]string, 0)
-	core := c.StringSlice()
-
-	args = append(args, "-i")
-	args = append(args, c.inputFp)
-	args = append(args, core...)
+	args = append(args, c.inputArgs()...)
+	args = append(args, c.args(false)...)
 	args = append(args, "-y")
 	args = append(args, "-pass")
 	args = append(args, "2")
 	args = append(args, "-passlogfile")
 	args = append(args, passlogfp)
-	args = append(args, c.outputFp)
+	args = append(args, c.target())
+	for n, o := range c.outputs {
+		args = append(args, o.variantArgs(false)...)
+		args = append(args, "-pass")
+		args = append(args, "2")
+		args = append(args, "-passlogfile")
+		args = append(args, variantPasslogfp(passlogfp, n))
+		args = append(args, o.target())
+	}
 
 	return args
 }
 
+// nullOutput returns the output which discards what's written to it
+func nullOutput() string {
+	if runtime.GOOS == "windows" {
+		return "NUL"
+	}
+	return "/dev/null"
+}
+
+// variantPasslogfp returns the prefix of the nth variant's first pass log
+func variantPasslogfp(passlogfp string, n int) string {
+	return fmt.Sprintf("%s-variant%d", passlogfp, n+1)
+}
+
+// SetReporter sets where the command reports its progress and
+// what ffmpeg logs while it runs, by default nothing is reported
+func (c *Command) SetReporter(r Reporter) {
+	c.reporter = r
+	for _, o := range c.outputs {
+		o.reporter = r
+	}
+}
+
+func (c *Command) report(e Event) {
+	e.Time = time.Now()
+	e.Output = c.outputFp
+	c.reporter.Report(e)
+}
+
 func (c *Command) Run() error {
+	if err := c.ValidOutputs(); err != nil {
+		return err
+	}
+
+	// Encode into temporary files so the outputs are only
+	// replaced once every pass has succeeded
+	outputs := append([]*Command{c}, c.outputs...)
+	for _, o := range outputs {
+		fp, err := tempFp(o.outputFp)
+		if err != nil {
+			return err
+		}
+		o.writeFp = fp
+		defer func(o *Command) {
+			os.Remove(o.writeFp)
+			o.writeFp = ""
+		}(o)
+	}
+
 	var passlogfp string
-	var p1, p2 *exec.Cmd
 
 	// Get the passlogfp if needed
 	if c.twoPass {
@@ -250,34 +508,77 @@ func (c *Command) Run() error {
 		passlogfp = file.Name()
 	}
 
-	// Create processes for the first and second pass and run them
-	p1 = exec.Command("ffmpeg", c.firstPassArgs(passlogfp)...)
-	p1.Stdout = os.Stdout
-	p1.Stderr = os.Stderr
-
 	// Run the commands
-	fmt.Println("------------STARTING------------")
-	fmt.Println("---------RUNNING-PASS-1---------")
-	fmt.Println(p1)
-	err := p1.Run()
-	if err != nil {
+	passes := 1
+	if c.twoPass {
+		passes = 2
+	}
+	if err := c.runPass(1, passes, c.firstPassArgs(passlogfp)); err != nil {
 		return err
 	}
-	fmt.Println("-----------PASS-1-DONE----------")
 	if c.twoPass {
-		p2 = exec.Command("ffmpeg", c.secondPassArgs(passlogfp)...)
-		p2.Stdout = os.Stdout
-		p2.Stderr = os.Stderr
+		if err := c.runPass(2, passes, c.secondPassArgs(passlogfp)); err != nil {
+			return err
+		}
+	}
 
-		fmt.Println("---------RUNNING-PASS-2---------")
-		fmt.Println(p2)
-		err := p2.Run()
-		if err != nil {
+	for _, o := range outputs {
+		if err := os.Rename(o.writeFp, o.outputFp); err != nil {
+			return err
+		}
+	}
+	for _, s := range c.sidecars {
+		if err := writeFileAtomic(s.fp, s.data); err != nil {
 			return err
 		}
-		fmt.Println("-----------PASS-2-DONE----------")
 	}
-	fmt.Println("--------------DONE--------------")
 
 	return nil
-}
\ No newline at end of file
+}
+
+// runPass runs ffmpeg with the args of a pass, if it fails then
+// the last lines of its stderr are returned in an ExecError
+func (c *Command) runPass(pass, passes int, args []string) error {
+	args = append(append([]string(nil), reportArgs...), args...)
+	c.report(Event{Kind: EventPassStart, Level: Info, Pass: pass, Passes: passes})
+	c.report(Event{Kind: EventCommand, Level: Info, Pass: pass, Passes: passes, Args: append([]string{"ffmpeg"}, args...)})
+
+	stderr := newStderrBuffer(stderrLines)
+	logs := newLineWriter(func(line string) {
+		level, line := parseLogLine(line)
+		stderr.push(line)
+		c.report(Event{Kind: EventLog, Level: level, Pass: pass, Passes: passes, Line: line})
+	})
+	pp := &progressParser{
+		p: Progress{Duration: c.duration},
+		emit: func(p Progress) {
+			c.report(Event{Kind: EventProgress, Level: Info, Pass: pass, Passes: passes, Progress: &p})
+		},
+	}
+	progress := newLineWriter(pp.line)
+
+	p := exec.Command("ffmpeg", args...)
+	p.Stdout = progress
+	p.Stderr = logs
+
+	start := time.Now()
+	err := p.Run()
+	logs.Flush()
+	progress.Flush()
+	elapsed := time.Since(start)
+	if err != nil {
+		err = newExecError(pass, args, stderr.Lines(), err)
+	}
+
+	var cpu time.Duration
+	if p.ProcessState != nil {
+		cpu = p.ProcessState.UserTime() + p.ProcessState.SystemTime()
+	}
+
+	level := Info
+	if err != nil {
+		level = Error
+	}
+	c.report(Event{Kind: EventPassEnd, Level: level, Pass: pass, Passes: passes, Elapsed: elapsed, CPU: cpu, Err: err})
+	return err
+}
@@ -0,0 +1,169 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StreamCopy remuxes the input into a WebM without re-encoding it,
+// this only works if the input's streams are already WebM compatible.
+// Cuts can only start on a keyframe so the trim should be snapped
+// with Inputs.SnapTrimToKeyframes beforehand
+type StreamCopy struct {
+	VideoCodec string // Codec name of the input's video stream as given by ffprobe
+	AudioCodec string // Codec name of the input's audio stream, empty if there's no audio
+}
+
+func NewStreamCopy() *StreamCopy {
+	return &StreamCopy{
+		VideoCodec: "",
+		AudioCodec: "",
+	}
+}
+
+func (sc *StreamCopy) ValidCodecs() bool {
+	switch strings.ToLower(sc.VideoCodec) {
+	case "vp8", "vp9", "av1":
+	default:
+		return false
+	}
+
+	switch strings.ToLower(sc.AudioCodec) {
+	case "", "opus", "vorbis":
+	default:
+		return false
+	}
+
+	return true
+}
+
+// SnapToKeyframes moves the start and end of the trim onto the nearest
+// keyframes in kf, it returns whether either of the times were changed
+func (tf *TrimFilter) SnapToKeyframes(kf []float64) (bool, error) {
+	if len(kf) == 0 {
+		return false, nil
+	}
+
+	snapped := false
+	var start float64
+	if tf.ValidStart() {
+		d, err := parseTrimTime(tf.Start)
+		if err != nil {
+			return false, err
+		}
+		start = nearestKeyframe(kf, d.Seconds())
+		if start != d.Seconds() {
+			tf.Start = strconv.FormatFloat(start, 'f', -1, 64)
+			snapped = true
+		}
+	}
+
+	if tf.ValidEnd() {
+		d, err := parseTrimTime(tf.End)
+		if err != nil {
+			return false, err
+		}
+		end := nearestKeyframe(kf, d.Seconds())
+		// If the end snapped onto the start then use the
+		// next keyframe so the clip isn't empty
+		if end <= start {
+			for _, k := range kf {
+				if k > start {
+					end = k
+					break
+				}
+			}
+		}
+		if end != d.Seconds() {
+			tf.End = strconv.FormatFloat(end, 'f', -1, 64)
+			snapped = true
+		}
+	}
+
+	return snapped, nil
+}
+
+// SnapTrimToKeyframes checks the input can be stream copied then moves
+// the trim onto the nearest keyframes, it returns whether the trim was
+// changed. The keyframes are only read if the input is trimmed
+func (i *Inputs) SnapTrimToKeyframes() (bool, error) {
+	if err := i.preprocessCopy(); err != nil {
+		return false, err
+	}
+	if !i.trimming() {
+		return false, nil
+	}
+
+	kf, err := Keyframes(i.InputFp, i.Trim.VideoDuration)
+	if err != nil {
+		return false, err
+	}
+	return i.Trim.SnapToKeyframes(kf)
+}
+
+func (i *Inputs) copyCommand() (*Command, error) {
+	if err := i.preprocessCopy(); err != nil {
+		return nil, err
+	}
+
+	i.c = newCommand()
+
+	// Input args
+	i.c.twoPass = false
+	i.c.addInput(i.InputFp)
+	i.c.outputFp = i.OutputFp
+	if err := i.processTrimSeek(); err != nil {
+		return nil, err
+	}
+
+	// Map args
+	i.processMapStreams()
+
+	// General Args
+	i.c.addGeneralArg("-c", "copy")
+	i.c.addGeneralArg("-metadata", fmt.Sprintf("title=\"%s\"", i.Title))
+	// Cutting on a keyframe can still leave audio packets with
+	// negative timestamps, shift everything so they start at zero
+	i.c.addGeneralArg("-avoid_negative_ts", "make_zero")
+	i.c.addGeneralArg("-f", "webm")
+
+	// The codecs are whatever the input was encoded with
+	i.c.expect = NewExpectation()
+	i.c.expect.Format = "webm"
+	i.c.expect.Width, i.c.expect.Height = i.Width, i.Height
+	if d, err := i.OutputDuration(); err == nil && d > 0 {
+		i.c.expect.Duration = d
+	}
+
+	return i.c, nil
+}
+
+func (i *Inputs) preprocessCopy() error {
+	if !i.Copy.ValidCodecs() {
+		return ErrCopyCodec
+	}
+	if i.Format != WebM {
+		return ErrCopyFormat
+	}
+	if len(i.Variants) > 0 {
+		return ErrVariantOutput
+	}
+
+	// Nothing can be filtered without re-encoding
+	if i.Crop != nil || i.Rotate != nil || i.Flip != nil || i.Resize != nil || i.Pad != nil || i.Captions != nil || len(i.Overlays) > 0 || i.Fade != nil || i.Denoise != nil || i.Deinterlace != nil || i.Dub != nil || i.Cuts != nil || i.Speed != nil || i.Effect != nil || i.Music != nil || i.Framerate > 0 {
+		return ErrCopyFilter
+	}
+
+	if i.trimming() {
+		d, err := i.Trim.Duration()
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return ErrNegTrimDur
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CutList extracts several segments from the video, they're
+// either joined together or each written to its own file
+type CutList struct {
+	Segments  []*TrimFilter // Segments to extract, in the order they should be joined
+	Separate  bool          // Write each segment to its own numbered file instead of joining them
+	Crossfade float64       // Length in seconds of the crossfade between joined segments, 0 for hard cuts
+}
+
+func NewCutList() *CutList {
+	return &CutList{
+		Segments:  make([]*TrimFilter, 0),
+		Separate:  false,
+		Crossfade: 0,
+	}
+}
+
+// AddSegment appends a segment from start to end, the
+// times are in the same format as the TrimFilter
+func (cl *CutList) AddSegment(start, end string, videoDuration float64) {
+	seg := NewTrimFilter()
+	seg.Start = start
+	seg.End = end
+	seg.VideoDuration = videoDuration
+	cl.Segments = append(cl.Segments, seg)
+}
+
+func (cl *CutList) Valid() bool {
+	if len(cl.Segments) == 0 || cl.Crossfade < 0 {
+		return false
+	}
+
+	for _, seg := range cl.Segments {
+		d, err := seg.Duration()
+		if err != nil || d <= 0 {
+			return false
+		}
+		// A crossfade can't be longer than the segments it joins
+		if !cl.Separate && len(cl.Segments) > 1 && d.Seconds() <= cl.Crossfade {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Duration is the length of the joined segments
+func (cl *CutList) Duration() (time.Duration, error) {
+	var total time.Duration
+	for _, seg := range cl.Segments {
+		d, err := seg.Duration()
+		if err != nil {
+			return 0, err
+		}
+		total += d
+	}
+
+	// Each crossfade overlaps the segments it joins
+	joins := time.Duration(len(cl.Segments) - 1)
+	total -= joins * time.Duration(cl.Crossfade*float64(time.Second))
+
+	return total, nil
+}
+
+// bounds returns the start of the earliest segment and
+// the end of the latest segment in seconds, the end is
+// -1 if any segment runs until the end of the video
+func (cl *CutList) bounds() (float64, float64, error) {
+	start, end := -1.0, 0.0
+	for _, seg := range cl.Segments {
+		s, e, err := seg.seconds()
+		if err != nil {
+			return 0, 0, err
+		}
+		if start < 0 || s < start {
+			start = s
+		}
+		if e < 0 || end < 0 {
+			end = -1
+		} else if e > end {
+			end = e
+		}
+	}
+
+	return start, end, nil
+}
+
+// seconds returns the start and end of the trim in seconds, the
+// end is -1 if the trim runs until the end of the video
+func (tf *TrimFilter) seconds() (float64, float64, error) {
+	start, end := 0.0, -1.0
+
+	if tf.ValidStart() {
+		d, err := parseTrimTime(tf.Start)
+		if err != nil {
+			return 0, 0, err
+		}
+		start = d.Seconds()
+	}
+	if tf.ValidEnd() {
+		d, err := parseTrimTime(tf.End)
+		if err != nil {
+			return 0, 0, err
+		}
+		end = d.Seconds()
+	}
+
+	return start, end, nil
+}
+
+// segmentArg returns the trim filter arg for the segment when the
+// input has been seeked to offset seconds
+func (tf *TrimFilter) segmentArg(offset float64) (string, error) {
+	start, end, err := tf.seconds()
+	if err != nil {
+		return "", err
+	}
+
+	arg := fmt.Sprintf("start=%s", strconv.FormatFloat(start-offset, 'f', -1, 64))
+	if end >= 0 {
+		arg += fmt.Sprintf(":end=%s", strconv.FormatFloat(end-offset, 'f', -1, 64))
+	}
+
+	return arg, nil
+}
+
+// SegmentFp returns the filepath of the nth segment when the
+// segments are written separately, i.e. "out.webm" becomes
+// "out_001.webm" for the first segment
+func SegmentFp(fp string, n int) string {
+	ext := filepath.Ext(fp)
+	return fmt.Sprintf("%s_%03d%s", strings.TrimSuffix(fp, ext), n+1, ext)
+}
+
+// ReadCutFile reads the segments of a cut list from a file. If the file
+// starts with ";FFMETADATA1" then the chapters in it are used as the
+// segments, otherwise it's read as a CSV with a "start,end" per line
+func ReadCutFile(fp string, videoDuration float64) (*CutList, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header, err := r.Peek(len(";FFMETADATA1"))
+	if err == nil && string(header) == ";FFMETADATA1" {
+		return readFFMetadataCuts(r, videoDuration)
+	}
+	return readCSVCuts(r, videoDuration)
+}
+
+func readCSVCuts(r io.Reader, videoDuration float64) (*CutList, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	cl := NewCutList()
+	for n, rec := range records {
+		if len(rec) != 2 {
+			return nil, ErrCutList
+		}
+		start, end := strings.TrimSpace(rec[0]), strings.TrimSpace(rec[1])
+
+		// Allow the file to have a header
+		if _, err := parseTrimTime(start); err != nil {
+			if n == 0 {
+				continue
+			}
+			return nil, ErrCutList
+		}
+
+		cl.AddSegment(start, end, videoDuration)
+	}
+
+	return cl, nil
+}
+
+func readFFMetadataCuts(r io.Reader, videoDuration float64) (*CutList, error) {
+	type chapter struct {
+		timebase   float64
+		start, end int64
+	}
+
+	chapters := make([]*chapter, 0)
+	var current *chapter
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Sections
+		if strings.HasPrefix(line, "[") {
+			current = nil
+			if line == "[CHAPTER]" {
+				current = &chapter{timebase: 1.0 / 1000, end: -1}
+				chapters = append(chapters, current)
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		// Keys within a chapter
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "TIMEBASE":
+			tb := strings.SplitN(parts[1], "/", 2)
+			if len(tb) != 2 {
+				return nil, ErrCutList
+			}
+			num, err1 := strconv.ParseFloat(tb[0], 64)
+			den, err2 := strconv.ParseFloat(tb[1], 64)
+			if err1 != nil || err2 != nil || den == 0 {
+				return nil, ErrCutList
+			}
+			current.timebase = num / den
+		case "START":
+			v, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return nil, ErrCutList
+			}
+			current.start = v
+		case "END":
+			v, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return nil, ErrCutList
+			}
+			current.end = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	cl := NewCutList()
+	for _, c := range chapters {
+		start := strconv.FormatFloat(float64(c.start)*c.timebase, 'f', -1, 64)
+		end := ""
+		if c.end >= 0 {
+			end = strconv.FormatFloat(float64(c.end)*c.timebase, 'f', -1, 64)
+		}
+		cl.AddSegment(start, end, videoDuration)
+	}
+
+	return cl, nil
+}
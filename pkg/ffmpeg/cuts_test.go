@@ -0,0 +1,140 @@
+package ffmpeg
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// segment is the start and end of a segment of a cut list
+type segment struct {
+	start, end string
+}
+
+func checkSegments(t *testing.T, cl *CutList, expected []segment) {
+	t.Helper()
+
+	if len(cl.Segments) != len(expected) {
+		t.Fatalf("has %d segments, expected %d", len(cl.Segments), len(expected))
+	}
+	for n, seg := range cl.Segments {
+		if seg.Start != expected[n].start || seg.End != expected[n].end {
+			t.Errorf("segment %d is %q-%q, expected %q-%q", n, seg.Start, seg.End, expected[n].start, expected[n].end)
+		}
+		if seg.VideoDuration != 60 {
+			t.Errorf("segment %d has video duration %v, expected 60", n, seg.VideoDuration)
+		}
+	}
+}
+
+func TestReadCutFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		segments []segment
+		err      error
+	}{
+		{
+			name:     "csv",
+			data:     "0,5\n10.5,12\n00:00:20,00:00:30.250\n",
+			segments: []segment{{"0", "5"}, {"10.5", "12"}, {"00:00:20", "00:00:30.250"}},
+		},
+		{
+			name:     "csv with a header, comments and spaces",
+			data:     "start,end\n# the intro\n0, 5\n\n40 ,\n",
+			segments: []segment{{"0", "5"}, {"40", ""}},
+		},
+		{
+			name: "csv with a bad time",
+			data: "0,5\nten,12\n",
+			err:  ErrCutList,
+		},
+		{
+			name: "csv with too many columns",
+			data: "0,5,7\n",
+			err:  ErrCutList,
+		},
+		{
+			name: "ffmetadata",
+			data: ";FFMETADATA1\ntitle=Film\n\n" +
+				"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=5000\ntitle=Intro\n\n" +
+				"[CHAPTER]\nTIMEBASE=1/2\nSTART=20\nEND=25\n\n" +
+				"[STREAM]\ntitle=Audio\n\n" +
+				"[CHAPTER]\nSTART=40000\n",
+			segments: []segment{{"0", "5"}, {"10", "12.5"}, {"40", ""}},
+		},
+		{
+			name: "ffmetadata with a bad timebase",
+			data: ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/0\nSTART=0\nEND=5\n",
+			err:  ErrCutList,
+		},
+		{
+			name: "ffmetadata with a bad start",
+			data: ";FFMETADATA1\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=1.5\nEND=5\n",
+			err:  ErrCutList,
+		},
+	}
+
+	dir := t.TempDir()
+	for n, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp := filepath.Join(dir, strconv.Itoa(n)+".txt")
+			if err := ioutil.WriteFile(fp, []byte(tt.data), 0666); err != nil {
+				t.Fatal(err)
+			}
+
+			cl, err := ReadCutFile(fp, 60)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error is %v, expected %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSegments(t, cl, tt.segments)
+		})
+	}
+}
+
+func TestReadCutFileMissing(t *testing.T) {
+	if _, err := ReadCutFile(filepath.Join(t.TempDir(), "missing.csv"), 60); err == nil {
+		t.Error("a missing file was read")
+	}
+}
+
+func TestParseCuts(t *testing.T) {
+	tests := []struct {
+		s        string
+		segments []segment
+		err      error
+	}{
+		{s: "0-5", segments: []segment{{"0", "5"}}},
+		{s: "0-5, 10-12.5,40-", segments: []segment{{"0", "5"}, {"10", "12.5"}, {"40", ""}}},
+		{s: "00:00:10-00:00:20", segments: []segment{{"00:00:10", "00:00:20"}}},
+		{s: "-5", err: ErrCutList},
+		{s: "5", err: ErrCutList},
+		{s: "a-b", err: ErrCutList},
+		{s: "0-5,", err: ErrCutList},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			i := NewInputs()
+			err := i.ParseCuts(tt.s, 60)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error is %v, expected %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSegments(t, i.Cuts, tt.segments)
+		})
+	}
+}
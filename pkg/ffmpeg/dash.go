@@ -0,0 +1,252 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Resources:
+// http://wiki.webmproject.org/adaptive-streaming/instructions-to-playback-adaptive-webm-using-dash
+// https://ffmpeg.org/ffmpeg-all.html#webm_005fdash_005fmanifest
+
+// Rung is one representation in the DASH ladder
+type Rung struct {
+	Height  int // Height of the video, the width keeps the aspect ratio
+	Bitrate int // Target bitrate of the video in Kbps
+}
+
+// DefaultRungs are the resolutions and bitrates recommended for VP9
+var DefaultRungs = []Rung{
+	{Height: 1080, Bitrate: 1800},
+	{Height: 720, Bitrate: 1024},
+	{Height: 480, Bitrate: 750},
+	{Height: 360, Bitrate: 276},
+	{Height: 240, Bitrate: 150},
+}
+
+// DashLadder encodes the input into a WebM for each rung of the ladder
+// and a WebM of the audio, then writes an MPD manifest describing them
+// so players can switch between the rungs as their bandwidth changes.
+// Every rung shares the same framerate and keyframe interval so their
+// keyframes line up and they can be switched between at any segment
+type DashLadder struct {
+	Rungs           []Rung
+	SegmentDuration float64 // Seconds between each keyframe
+	Framerate       float64 // Framerate of every rung
+}
+
+func NewDashLadder() *DashLadder {
+	rungs := make([]Rung, len(DefaultRungs))
+	copy(rungs, DefaultRungs)
+
+	return &DashLadder{
+		Rungs:           rungs,
+		SegmentDuration: 2,
+		Framerate:       -1,
+	}
+}
+
+func (dl *DashLadder) Valid() bool {
+	if len(dl.Rungs) == 0 || dl.SegmentDuration <= 0 || dl.Framerate <= 0 {
+		return false
+	}
+
+	// Rungs of the same height would be written to the same file
+	heights := make(map[int]bool, len(dl.Rungs))
+	for _, r := range dl.Rungs {
+		if r.Height < 2 || r.Height > MaxDimension || r.Bitrate <= 0 || heights[r.Height] {
+			return false
+		}
+		heights[r.Height] = true
+	}
+	return true
+}
+
+// Sort orders the rungs from the largest to the smallest
+func (dl *DashLadder) Sort() {
+	sort.SliceStable(dl.Rungs, func(a, b int) bool {
+		return dl.Rungs[a].Height > dl.Rungs[b].Height
+	})
+}
+
+// GOP returns the frames between each keyframe
+func (dl *DashLadder) GOP() int {
+	return int(math.Max(1, math.Round(dl.Framerate*dl.SegmentDuration)))
+}
+
+// KeyframeArgs fix the keyframe interval so every rung has its
+// keyframes at the same times, the encoder can't place its own
+func (dl *DashLadder) KeyframeArgs() [][]string {
+	gop := strconv.Itoa(dl.GOP())
+	return [][]string{
+		{"-g", gop},
+		{"-keyint_min", gop},
+	}
+}
+
+// Within returns the rungs which don't upscale a video of the given
+// height, if they all do then the smallest rung is used at that height
+func (dl *DashLadder) Within(height int) []Rung {
+	rungs := make([]Rung, 0, len(dl.Rungs))
+	smallest := dl.Rungs[0]
+	for _, r := range dl.Rungs {
+		if height <= 0 || r.Height <= height {
+			rungs = append(rungs, r)
+		}
+		if r.Height < smallest.Height {
+			smallest = r
+		}
+	}
+
+	if len(rungs) == 0 {
+		rungs = append(rungs, Rung{Height: even(float64(height)), Bitrate: smallest.Bitrate})
+	}
+	return rungs
+}
+
+// RungFp returns the filepath of a rung which is written next to the manifest
+func RungFp(manifestFp string, r Rung) string {
+	return strings.TrimSuffix(manifestFp, filepath.Ext(manifestFp)) + fmt.Sprintf("_%dp.webm", r.Height)
+}
+
+// DashAudioFp returns the filepath of the audio which is written next to the manifest
+func DashAudioFp(manifestFp string) string {
+	return strings.TrimSuffix(manifestFp, filepath.Ext(manifestFp)) + "_audio.webm"
+}
+
+// ArgAdaptationSets groups the video rungs into one adaptation
+// set and the audio into another, the streams are the indexes
+// of the inputs of the manifest command
+func (dl *DashLadder) ArgAdaptationSets(videos int, audio bool) (string, string) {
+	streams := make([]string, 0, videos)
+	for n := 0; n < videos; n++ {
+		streams = append(streams, strconv.Itoa(n))
+	}
+
+	sets := "id=0,streams=" + strings.Join(streams, ",")
+	if audio {
+		sets += fmt.Sprintf(" id=1,streams=%d", videos)
+	}
+	return "-adaptation_sets", sets
+}
+
+// dashCommands returns a command for each rung of the ladder,
+// one for the audio and one which writes the manifest
+func (i *Inputs) dashCommands() ([]*Command, error) {
+	if err := i.preprocessDash(); err != nil {
+		return nil, err
+	}
+
+	_, h := i.resizeSource()
+	rungs := i.Dash.Within(h)
+
+	cmds := make([]*Command, 0, len(rungs)+3)
+	for n, r := range rungs {
+		ri := i.clone()
+		ri.Dash = nil
+		ri.Poster = ""
+		// The rungs are only video, the dubbed audio is encoded with the audio
+		ri.AudioEnabled = false
+		ri.Dub = nil
+		ri.Framerate = i.Dash.Framerate
+		ri.OutputFp = RungFp(i.OutputFp, r)
+		ri.Resize = NewResizeFilter()
+		ri.Resize.Width = -2
+		ri.Resize.Height = r.Height
+		if i.Resize != nil {
+			ri.Resize.Algorithm = i.Resize.Algorithm
+		}
+		ri.VarArgs.Bitrate = r.Bitrate
+
+		c, err := ri.Command()
+		if err != nil {
+			return nil, err
+		}
+		c.addVideoArgs(i.Dash.KeyframeArgs())
+		c.addGeneralArg("-dash", "1")
+		cmds = append(cmds, c)
+
+		// The poster is taken from the largest rung and
+		// written next to the manifest
+		if n == 0 && i.Poster != "" {
+			ri.Poster = i.Poster
+			cs, err := ri.withPoster(c)
+			if err != nil {
+				return nil, err
+			}
+			for _, pc := range cs[1:] {
+				pc.outputFp = PosterFp(i.OutputFp)
+			}
+			cmds = append(cmds, cs[1:]...)
+		}
+	}
+
+	audio := i.AudioEnabled && (i.AudioTrack.Index > -1 || i.Dub != nil)
+	if audio {
+		ai := i.clone()
+		ai.Dash = nil
+		ai.Poster = ""
+		ai.AudioOnly = true
+		ai.OutputFp = DashAudioFp(i.OutputFp)
+		ai.Crop, ai.Rotate, ai.Flip, ai.Resize, ai.Pad = nil, nil, nil, nil, nil
+		ai.Captions, ai.Overlays, ai.Denoise, ai.Deinterlace = nil, nil, nil, nil
+
+		c, err := ai.Command()
+		if err != nil {
+			return nil, err
+		}
+		c.addGeneralArg("-dash", "1")
+		cmds = append(cmds, c)
+	}
+
+	return append(cmds, i.manifestCommand(rungs, audio)), nil
+}
+
+// manifestCommand reads the headers of each rung and the audio to
+// write the manifest, none of the streams are re-encoded
+func (i *Inputs) manifestCommand(rungs []Rung, audio bool) *Command {
+	fps := make([]string, 0, len(rungs)+1)
+	for _, r := range rungs {
+		fps = append(fps, RungFp(i.OutputFp, r))
+	}
+	if audio {
+		fps = append(fps, DashAudioFp(i.OutputFp))
+	}
+
+	c := newCommand()
+	c.outputFp = i.OutputFp
+	for _, fp := range fps {
+		n := c.addInput(fp)
+		c.addInputArg(n, "-f", "webm_dash_manifest")
+		c.addMap(strconv.Itoa(n))
+	}
+	c.addGeneralArg("-c", "copy")
+	c.addGeneralArg("-f", "webm_dash_manifest")
+	c.addGeneralArg(i.Dash.ArgAdaptationSets(len(rungs), audio))
+
+	return c
+}
+
+func (i *Inputs) preprocessDash() error {
+	if i.Framerate > 0 {
+		i.Dash.Framerate = i.Framerate
+	}
+	if !i.Dash.Valid() {
+		return ErrDash
+	}
+	i.Dash.Sort()
+	if i.Codec != VP9 && i.Codec != AV1 {
+		return ErrDashCodec
+	}
+	if len(i.Variants) > 0 {
+		return ErrVariantOutput
+	}
+	if i.Copy != nil || i.AudioOnly || i.Format != WebM || (i.cutting() && i.Cuts.Separate) {
+		return ErrDashOutput
+	}
+	return nil
+}
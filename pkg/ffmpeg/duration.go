@@ -3,6 +3,7 @@ package ffmpeg
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -50,3 +51,9 @@ func parseTrimTime(t string) (time.Duration, error) {
 
 	return 0, errors.New("failed to parse trim time")
 }
+
+// formatSeconds formats a duration as seconds which
+// ffmpeg accepts for both options and filter args
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
@@ -3,13 +3,55 @@ package ffmpeg
 import "errors"
 
 var (
-	ErrThreadNum    = errors.New("invalid number of threads")
-	ErrInvalidCodec = errors.New("invalid codec specified")
-	ErrInvalidCRF   = errors.New("crf is not between 0 and 63")
-	ErrFramerate    = errors.New("framerate is too low")
-	ErrResize       = errors.New("invalid resize resolution")
-	ErrCrop         = errors.New("invalid crop dimensions")
-	ErrDub          = errors.New("invalid dub")
-	ErrNegTrimDur   = errors.New("trim duration is negative")
-	ErrAudioBitrate = errors.New("audio bitrate is too low")
+	ErrThreadNum       = errors.New("invalid number of threads")
+	ErrInvalidCodec    = errors.New("invalid codec specified")
+	ErrInvalidCRF      = errors.New("crf is not between 0 and 63")
+	ErrFramerate       = errors.New("framerate is too low")
+	ErrResize          = errors.New("invalid resize resolution")
+	ErrCrop            = errors.New("invalid crop dimensions")
+	ErrPad             = errors.New("invalid padding")
+	ErrOverlay         = errors.New("invalid overlay")
+	ErrCaption         = errors.New("invalid caption")
+	ErrRotate          = errors.New("rotation must be 90, 180 or 270 degrees")
+	ErrFade            = errors.New("fades must be positive and fit within the output")
+	ErrDub             = errors.New("invalid dub")
+	ErrMusic           = errors.New("invalid music video, the visualiser needs an even resolution")
+	ErrMusicAudio      = errors.New("music videos need audio from the input and can't be dubbed")
+	ErrMusicCover      = errors.New("the audio has no embedded cover art, specify a cover image")
+	ErrAudioOnly       = errors.New("audio only outputs need audio from the input or a dub")
+	ErrAudioOnlyFilter = errors.New("video filters can't be used when only outputting audio")
+	ErrChannels        = errors.New("invalid audio channels")
+	ErrOpus            = errors.New("invalid opus options")
+	ErrFormat          = errors.New("invalid format specified")
+	ErrFormatAudio     = errors.New("audio only outputs can't be animated images")
+	ErrAnimation       = errors.New("invalid animation options")
+	ErrCopyFormat      = errors.New("only webm outputs can be stream copied")
+	ErrThumbs          = errors.New("invalid thumbnail options")
+	ErrThumbsInput     = errors.New("thumbnails need a video with a known duration and dimensions")
+	ErrThumbsNone      = errors.New("no thumbnails were specified, use a poster, sheet or sprite")
+	ErrDash            = errors.New("invalid dash ladder, it needs rungs of different heights and a known framerate")
+	ErrDashCodec       = errors.New("dash ladders must be encoded with vp9 or av1")
+	ErrDashOutput      = errors.New("dash ladders can't be copied, audio only, animated or separate cuts")
+	ErrVariant         = errors.New("invalid variant, it needs an output and a crf from 0 to 63")
+	ErrVariantOutput   = errors.New("variants need a webm output of their own and can't be separate cuts or dash ladders")
+	ErrUnknownEncoder  = errors.New("unknown encoder, ffmpeg may not be built with it")
+	ErrFilterArg       = errors.New("invalid filter or filter argument")
+	ErrNoSuchFile      = errors.New("no such file or directory")
+	ErrInvalidData     = errors.New("invalid data in the input")
+	ErrNoSpace         = errors.New("no space left on device")
+	ErrLevel           = errors.New("invalid log level")
+	ErrOutputInput     = errors.New("the output can't be written over the input")
+	ErrVerify          = errors.New("output failed verification")
+	ErrFFmpegVersion   = errors.New("unknown ffmpeg version")
+	ErrNegTrimDur      = errors.New("trim duration is negative")
+	ErrAudioBitrate    = errors.New("audio bitrate is too low")
+	ErrCopyCodec       = errors.New("input codecs can't be copied into a webm")
+	ErrCopyFilter      = errors.New("filters can't be used when copying streams")
+	ErrSpeed           = errors.New("speed must be above zero")
+	ErrEffect          = errors.New("invalid effect specified")
+	ErrEffectLength    = errors.New("clip is too long to repeat the effect")
+	ErrEffectMemory    = errors.New("effect needs more memory than allowed, shorten or shrink the clip")
+	ErrCutList         = errors.New("invalid cut list")
+	ErrCutTrim         = errors.New("can't trim and use a cut list at the same time")
+	ErrCutSeparate     = errors.New("separate cuts need a command each, use Inputs.Commands")
 )
@@ -0,0 +1,134 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// How many of the last lines ffmpeg writes to stderr are kept
+const stderrLines = 20
+
+// failures map what ffmpeg writes to stderr to the error it's classified
+// as, they're checked in order so the more specific causes come first
+var failures = []struct {
+	kind     error
+	patterns []string
+}{
+	{ErrNoSpace, []string{"No space left on device"}},
+	{ErrNoSuchFile, []string{"No such file or directory"}},
+	{ErrUnknownEncoder, []string{"Unknown encoder", "Encoder not found"}},
+	{ErrFilterArg, []string{
+		"No such filter",
+		"Error parsing a filter description",
+		"Error parsing filterchain",
+		"Error applying option",
+		"Error initializing filter",
+		"Error initializing complex filters",
+		"Error reinitializing filters",
+		"Failed to configure",
+	}},
+	{ErrInvalidData, []string{
+		"Invalid data found when processing input",
+		"Invalid NAL unit",
+		"corrupt decoded frame",
+		"corrupt input packet",
+		"Packet corrupt",
+	}},
+}
+
+// ExecError is returned when ffmpeg fails. It's classified from the
+// last lines ffmpeg wrote to stderr so errors.Is can be used with the
+// kind of failure, i.e. errors.Is(err, ErrNoSpace)
+type ExecError struct {
+	Kind   error    // Kind of failure, nil if it wasn't recognised
+	Pass   int      // Pass which failed
+	Args   []string // Args ffmpeg was run with
+	Stderr []string // Last lines ffmpeg wrote to stderr
+	Err    error    // Error from running ffmpeg, i.e. its exit status
+}
+
+func newExecError(pass int, args, stderr []string, err error) *ExecError {
+	e := &ExecError{
+		Kind:   nil,
+		Pass:   pass,
+		Args:   args,
+		Stderr: stderr,
+		Err:    err,
+	}
+	e.Kind, _ = e.classify()
+	return e
+}
+
+// classify returns the kind of failure and the line which shows it
+func (e *ExecError) classify() (error, string) {
+	for _, f := range failures {
+		for _, line := range e.Stderr {
+			for _, p := range f.patterns {
+				if strings.Contains(line, p) {
+					return f.kind, line
+				}
+			}
+		}
+	}
+	return nil, ""
+}
+
+func (e *ExecError) Error() string {
+	kind, line := e.classify()
+	if kind == nil {
+		kind = e.Err
+		if len(e.Stderr) > 0 {
+			line = e.Stderr[len(e.Stderr)-1]
+		}
+	}
+
+	if line == "" {
+		return fmt.Sprintf("ffmpeg pass %d: %v", e.Pass, kind)
+	}
+	return fmt.Sprintf("ffmpeg pass %d: %v: %s", e.Pass, kind, line)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExecError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// Command returns the ffmpeg command which failed
+func (e *ExecError) Command() string {
+	return "ffmpeg " + strings.Join(e.Args, " ")
+}
+
+// stderrBuffer keeps the last lines ffmpeg wrote to stderr
+type stderrBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	max   int
+}
+
+func newStderrBuffer(max int) *stderrBuffer {
+	return &stderrBuffer{
+		lines: make([]string, 0, max),
+		max:   max,
+	}
+}
+
+func (sb *stderrBuffer) push(line string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if len(sb.lines) == sb.max {
+		sb.lines = sb.lines[1:]
+	}
+	sb.lines = append(sb.lines, line)
+}
+
+// Lines returns the last lines which were written
+func (sb *stderrBuffer) Lines() []string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return append([]string(nil), sb.lines...)
+}
@@ -0,0 +1,202 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExecErrorClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr []string
+		kind   error
+		line   string
+	}{
+		{
+			name: "no space",
+			stderr: []string{
+				"frame=  120 fps= 30 q=-0.0 size=    1024kB time=00:00:04.00 bitrate=2097.2kbits/s speed=1.0x",
+				"av_interleaved_write_frame(): No space left on device",
+				"Error writing trailer of out.webm: No space left on device",
+				"Conversion failed!",
+			},
+			kind: ErrNoSpace,
+			line: "av_interleaved_write_frame(): No space left on device",
+		},
+		{
+			name:   "missing input",
+			stderr: []string{"missing.mp4: No such file or directory"},
+			kind:   ErrNoSuchFile,
+			line:   "missing.mp4: No such file or directory",
+		},
+		{
+			name: "unknown encoder",
+			stderr: []string{
+				"Stream mapping:",
+				"  Stream #0:0 -> #0:0 (h264 (native) -> av1 (libsvtav1))",
+				"Unknown encoder 'libsvtav1'",
+			},
+			kind: ErrUnknownEncoder,
+			line: "Unknown encoder 'libsvtav1'",
+		},
+		{
+			name: "opening an encoder isn't an unknown encoder",
+			stderr: []string{
+				"[libvpx-vp9 @ 0x55d0c1e2a4c0] Failed to initialize encoder: Invalid parameter",
+				"Error while opening encoder for output stream #0:0 - maybe incorrect parameters such as bit_rate, rate, width or height",
+				"Conversion failed!",
+			},
+			kind: nil,
+		},
+		{
+			name: "unknown filter",
+			stderr: []string{
+				"[AVFilterGraph @ 0x55d0c1e2a4c0] No such filter: 'scael'",
+				"Error initializing complex filters.",
+				"Invalid argument",
+			},
+			kind: ErrFilterArg,
+			line: "[AVFilterGraph @ 0x55d0c1e2a4c0] No such filter: 'scael'",
+		},
+		{
+			name: "filter option",
+			stderr: []string{
+				"[Parsed_scale_0 @ 0x55d0c1e2a4c0] Error applying option 'flgas' to filter 'scale': Option not found",
+			},
+			kind: ErrFilterArg,
+			line: "[Parsed_scale_0 @ 0x55d0c1e2a4c0] Error applying option 'flgas' to filter 'scale': Option not found",
+		},
+		{
+			name:   "invalid input",
+			stderr: []string{"in.mp4: Invalid data found when processing input"},
+			kind:   ErrInvalidData,
+			line:   "in.mp4: Invalid data found when processing input",
+		},
+		{
+			name: "corrupt packet",
+			stderr: []string{
+				"[mov,mp4,m4a,3gp,3g2,mj2 @ 0x55d0c1e2a4c0] Packet corrupt (stream = 0, dts = 1001).",
+				"Conversion failed!",
+			},
+			kind: ErrInvalidData,
+			line: "[mov,mp4,m4a,3gp,3g2,mj2 @ 0x55d0c1e2a4c0] Packet corrupt (stream = 0, dts = 1001).",
+		},
+		{
+			name: "invalid nal unit",
+			stderr: []string{
+				"[h264 @ 0x55d0c1e2a4c0] Invalid NAL unit size (1633 > 1024).",
+			},
+			kind: ErrInvalidData,
+			line: "[h264 @ 0x55d0c1e2a4c0] Invalid NAL unit size (1633 > 1024).",
+		},
+		{
+			name: "corrupt isn't enough on its own",
+			stderr: []string{
+				"[matroska,webm @ 0x55d0c1e2a4c0] Read error at pos. 1024 (0x400), the file may be corrupt",
+				"Conversion failed!",
+			},
+			kind: nil,
+		},
+		{
+			// A filter failing because the disk is full is still a full disk
+			name: "specific causes first",
+			stderr: []string{
+				"Error reinitializing filters!",
+				"out.webm: No space left on device",
+			},
+			kind: ErrNoSpace,
+			line: "out.webm: No space left on device",
+		},
+		{
+			name:   "unrecognised",
+			stderr: []string{"Conversion failed!"},
+			kind:   nil,
+		},
+		{
+			name:   "no stderr",
+			stderr: nil,
+			kind:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newExecError(1, nil, tt.stderr, errors.New("exit status 1"))
+			kind, line := e.classify()
+			if kind != tt.kind {
+				t.Errorf("kind is %v, expected %v", kind, tt.kind)
+			}
+			if line != tt.line {
+				t.Errorf("line is %q, expected %q", line, tt.line)
+			}
+			if e.Kind != tt.kind {
+				t.Errorf("Kind is %v, expected %v", e.Kind, tt.kind)
+			}
+			if tt.kind != nil && !errors.Is(e, tt.kind) {
+				t.Errorf("errors.Is(err, %v) is false", tt.kind)
+			}
+		})
+	}
+}
+
+func TestExecErrorError(t *testing.T) {
+	exit := errors.New("exit status 1")
+	tests := []struct {
+		name   string
+		stderr []string
+		msg    string
+	}{
+		{
+			name:   "classified",
+			stderr: []string{"out.webm: No space left on device", "Conversion failed!"},
+			msg:    fmt.Sprintf("ffmpeg pass 2: %v: out.webm: No space left on device", ErrNoSpace),
+		},
+		{
+			name:   "last line",
+			stderr: []string{"Something went wrong", "Conversion failed!"},
+			msg:    "ffmpeg pass 2: exit status 1: Conversion failed!",
+		},
+		{
+			name:   "no stderr",
+			stderr: nil,
+			msg:    "ffmpeg pass 2: exit status 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newExecError(2, nil, tt.stderr, exit)
+			if e.Error() != tt.msg {
+				t.Errorf("message is %q, expected %q", e.Error(), tt.msg)
+			}
+			if !errors.Is(e, exit) {
+				t.Error("the exit status isn't unwrapped")
+			}
+		})
+	}
+}
+
+func TestStderrBuffer(t *testing.T) {
+	sb := newStderrBuffer(3)
+	for n := 1; n <= 5; n++ {
+		sb.push(fmt.Sprintf("line %d", n))
+	}
+
+	lines := sb.Lines()
+	expected := []string{"line 3", "line 4", "line 5"}
+	if len(lines) != len(expected) {
+		t.Fatalf("kept %d lines, expected %d", len(lines), len(expected))
+	}
+	for n := range expected {
+		if lines[n] != expected[n] {
+			t.Errorf("line %d is %q, expected %q", n, lines[n], expected[n])
+		}
+	}
+
+	// The lines returned aren't changed by later pushes
+	sb.push("line 6")
+	if lines[0] != "line 3" {
+		t.Errorf("returned lines changed to %q", lines[0])
+	}
+}
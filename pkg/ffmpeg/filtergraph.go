@@ -0,0 +1,156 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filter is a single ffmpeg filter e.g. "scale=1280:720"
+type filter struct {
+	name string
+	args string
+}
+
+func (f filter) String() string {
+	if f.args == "" {
+		return f.name
+	}
+	return fmt.Sprintf("%s=%s", f.name, f.args)
+}
+
+// firstPass returns the filter which should be used on the first pass
+func (f filter) firstPass() filter {
+	// Infinitely looping filters cause infinite loops on the first pass so we
+	// replace them with null equivalents which simply pass through the stream
+	if !strings.HasPrefix(f.args, "-1") {
+		return f
+	}
+	switch f.name {
+	case "loop":
+		return filter{name: "null"}
+	case "aloop":
+		return filter{name: "anull"}
+	}
+	return f
+}
+
+// filterChain is a list of filters joined together with commas,
+// it reads from its input labels and writes to its output labels
+type filterChain struct {
+	inputs  []string
+	filters []filter
+	outputs []string
+}
+
+func (fc filterChain) String(firstPass bool) string {
+	var str string
+
+	for _, l := range fc.inputs {
+		str += fmt.Sprintf("[%s]", l)
+	}
+	filters := make([]string, 0, len(fc.filters))
+	for _, f := range fc.filters {
+		if firstPass {
+			f = f.firstPass()
+		}
+		filters = append(filters, f.String())
+	}
+	str += strings.Join(filters, ",")
+	for _, l := range fc.outputs {
+		str += fmt.Sprintf("[%s]", l)
+	}
+
+	return str
+}
+
+// filterStream follows a stream through the filtergraph. Filters
+// are queued onto the stream until it's closed into a chain
+type filterStream struct {
+	label   string   // Either an input stream specifier e.g. "0:v:0" or a filtergraph label
+	filters []filter // Filters which haven't been closed into a chain yet
+}
+
+func newFilterStream(label string) *filterStream {
+	return &filterStream{
+		label:   label,
+		filters: make([]filter, 0),
+	}
+}
+
+// filtered returns whether the stream passes through the filtergraph
+func (fs *filterStream) filtered() bool {
+	return len(fs.filters) > 0 || !isStreamSpecifier(fs.label)
+}
+
+// mapArg returns the arg which maps the stream into the output,
+// if the stream has unclosed filters then out is its label
+func (fs *filterStream) mapArg(out string) string {
+	if len(fs.filters) > 0 {
+		return fmt.Sprintf("[%s]", out)
+	}
+	if isStreamSpecifier(fs.label) {
+		return fs.label
+	}
+	return fmt.Sprintf("[%s]", fs.label)
+}
+
+// isStreamSpecifier returns whether the label refers to an input
+// stream, filtergraph labels never contain a colon
+func isStreamSpecifier(label string) bool {
+	return strings.Contains(label, ":")
+}
+
+// escapeFilterArg escapes a value so it's passed to a filter's option
+// unchanged. The value is unescaped twice by ffmpeg, once when the
+// filtergraph is parsed and again when the filter parses its options,
+// so it's quoted for the filter then escaped for the filtergraph
+func escapeFilterArg(v string) string {
+	quoted := "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+
+	var escaped strings.Builder
+	for _, r := range quoted {
+		if strings.ContainsRune(`\'[],;`, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+
+	return escaped.String()
+}
+
+// enableWindow returns the expression which enables a filter between
+// start and end, it's empty if the filter is always enabled. The times
+// accept "HH:MM:SS.MS/HH:MM:SS/S" and must have already been validated
+func enableWindow(start, end string) string {
+	if start == "" && end == "" {
+		return ""
+	}
+
+	s := 0.0
+	if start != "" {
+		d, _ := parseTrimTime(start)
+		s = d.Seconds()
+	}
+	if end == "" {
+		return fmt.Sprintf("gte(t,%s)", strconv.FormatFloat(s, 'f', -1, 64))
+	}
+
+	e, _ := parseTrimTime(end)
+	return fmt.Sprintf("between(t,%s,%s)", strconv.FormatFloat(s, 'f', -1, 64), strconv.FormatFloat(e.Seconds(), 'f', -1, 64))
+}
+
+// validWindow returns whether the times of an enable window can be parsed
+func validWindow(start, end string) bool {
+	if start != "" {
+		if _, err := parseTrimTime(start); err != nil {
+			return false
+		}
+	}
+	if end != "" {
+		if _, err := parseTrimTime(end); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+// getToken reads a token the way ffmpeg's av_get_token does, a
+// backslash escapes the next character and quoted text is read as
+// it is, the token ends at the first unescaped character in term
+func getToken(s, term string) (string, string) {
+	var b strings.Builder
+	for n := 0; n < len(s); n++ {
+		switch c := s[n]; {
+		case c == '\\' && n+1 < len(s):
+			n++
+			b.WriteByte(s[n])
+		case c == '\'':
+			end := strings.IndexByte(s[n+1:], '\'')
+			if end < 0 {
+				end = len(s) - n - 1
+			}
+			b.WriteString(s[n+1 : n+1+end])
+			n += end + 1
+		case strings.IndexByte(term, c) > -1:
+			return b.String(), s[n:]
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), ""
+}
+
+func TestEscapeFilterArg(t *testing.T) {
+	tests := []struct {
+		v       string
+		escaped string
+	}{
+		{"hello", `\'hello\'`},
+		{"black@0.5", `\'black@0.5\'`},
+		{"a:b", `\'a:b\'`},
+		{"a,b;c", `\'a\,b\;c\'`},
+		{"[out]", `\'\[out\]\'`},
+		{"it's", `\'it\'\\\'\'s\'`},
+		{`C:\fonts\a.ttf`, `\'C:\\fonts\\a.ttf\'`},
+		{"%{pts:hms}", `\'%{pts:hms}\'`},
+		{"", `\'\'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.v, func(t *testing.T) {
+			escaped := escapeFilterArg(tt.v)
+			if escaped != tt.escaped {
+				t.Errorf("escaped as %s, expected %s", escaped, tt.escaped)
+			}
+
+			// The filtergraph is parsed then the filter's options
+			option, rest := getToken(escaped, "[],;")
+			if rest != "" {
+				t.Errorf("filtergraph stopped reading before %q", rest)
+			}
+			v, rest := getToken(option, ":")
+			if rest != "" {
+				t.Errorf("filter stopped reading before %q", rest)
+			}
+			if v != tt.v {
+				t.Errorf("unescaped as %q, expected %q", v, tt.v)
+			}
+		})
+	}
+}
@@ -3,7 +3,8 @@ package ffmpeg
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"math"
+	"strconv"
 	"time"
 )
 
@@ -28,19 +29,28 @@ func (tf *TrimFilter) ValidEnd() bool {
 	return tf.End != ""
 }
 
-func (tf *TrimFilter) FilterArg() string {
-	var arg string
+// SeekArgs returns the input options which seek the input to the trim
+// window, this lets ffmpeg skip decoding everything before the start.
+// They must be placed before the input's "-i"
+func (tf *TrimFilter) SeekArgs() ([][]string, error) {
+	args := make([][]string, 0)
 
 	if tf.ValidStart() {
-		arg += fmt.Sprintf("start=%s", strings.ReplaceAll(tf.Start, ":", "\\\\:"))
-		//arg += fmt.Sprintf("start='%s'", strings.ReplaceAll(tf.Start, ":", "\\:"))
+		start, err := parseTrimTime(tf.Start)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, []string{"-ss", formatSeconds(start)})
 	}
 	if tf.ValidEnd() {
-		arg += fmt.Sprintf(":end=%s", strings.ReplaceAll(tf.End, ":", "\\\\:"))
-		//arg += fmt.Sprintf(":end='%s'", strings.ReplaceAll(tf.End, ":", "\\:"))
+		end, err := parseTrimTime(tf.End)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, []string{"-to", formatSeconds(end)})
 	}
 
-	return strings.TrimPrefix(arg, ":")
+	return args, nil
 }
 
 func (tf *TrimFilter) StartDuration() (time.Duration, error) {
@@ -87,38 +97,13 @@ func (tf *TrimFilter) Duration() (time.Duration, error) {
 			return 0, err
 		}
 
-		dur, err := time.ParseDuration(fmt.Sprintf("%ds", int(tf.VideoDuration)))
-		if err != nil {
-			return 0, err
-		}
-
+		dur := time.Duration(tf.VideoDuration * float64(time.Second))
 		return dur - start, nil
 	}
 
 	return 0, errors.New("shouldn't be here")
 }
 
-// ResizeFilter resizes a video into a new width and height
-type ResizeFilter struct {
-	Width, Height int // Width and Height in pixels
-}
-
-func NewResizeFilter() *ResizeFilter {
-	return &ResizeFilter{
-		Width:  0,
-		Height: 0,
-	}
-}
-
-func (rf *ResizeFilter) ValidResolution() bool {
-	return true // We allow -1 etc.
-	//return rf.Width > 0 && rf.Height > 0
-}
-
-func (rf *ResizeFilter) Args() (string, string) {
-	return "scale", fmt.Sprintf("%d:%d:flags=lanczos", rf.Width, rf.Height)
-}
-
 // CropFilter crops a video onto a specified crop window
 type CropFilter struct {
 	X, Y int // Coordinates where the origin (top-left) of the crop window should start
@@ -138,10 +123,220 @@ func (cf *CropFilter) ValidCrop() bool {
 	return cf.X >= 0 && cf.Y >= 0 && cf.W > 0 && cf.H > 0
 }
 
+// ValidWithin returns whether the crop window fits in a video of width by height
+func (cf *CropFilter) ValidWithin(width, height int) bool {
+	return cf.X+cf.W <= width && cf.Y+cf.H <= height
+}
+
 func (cf *CropFilter) Args() (string, string) {
 	return "crop", fmt.Sprintf("%d:%d:%d:%d", cf.W, cf.H, cf.X, cf.Y)
 }
 
+// SpeedFilter changes the playback speed of the
+// video, the audio is kept at the same pitch
+type SpeedFilter struct {
+	Factor      float64 // Speed multiplier, i.e. 2 is twice as fast and 0.5 is half as fast
+	Interpolate bool    // Interpolate new frames when slowing the video down
+	Framerate   float64 // Framerate the slowed video should be interpolated back up to
+}
+
+func NewSpeedFilter() *SpeedFilter {
+	return &SpeedFilter{
+		Factor:      1,
+		Interpolate: false,
+		Framerate:   0,
+	}
+}
+
+func (sf *SpeedFilter) Valid() bool {
+	return sf.Factor > 0 && sf.Factor != 1
+}
+
+func (sf *SpeedFilter) Args() (string, string) {
+	return "setpts", fmt.Sprintf("PTS/%s", strconv.FormatFloat(sf.Factor, 'f', -1, 64))
+}
+
+// AudioArgs returns the atempo filters needed to change the speed of the
+// audio, each atempo filter only accepts a factor between 0.5 and 2.0
+// so larger changes are made by chaining several of them together
+func (sf *SpeedFilter) AudioArgs() [][]string {
+	args := make([][]string, 0)
+
+	f := sf.Factor
+	for f > 2 {
+		args = append(args, []string{"atempo", "2"})
+		f /= 2
+	}
+	for f < 0.5 {
+		args = append(args, []string{"atempo", "0.5"})
+		f /= 0.5
+	}
+	args = append(args, []string{"atempo", strconv.FormatFloat(f, 'f', -1, 64)})
+
+	return args
+}
+
+// ValidInterpolation returns whether new frames should be interpolated,
+// this is only useful for slow motion since no frames are missing otherwise
+func (sf *SpeedFilter) ValidInterpolation() bool {
+	return sf.Interpolate && sf.Factor < 1
+}
+
+func (sf *SpeedFilter) ArgInterpolate() (string, string) {
+	// Motion compensated interpolation gives the smoothest
+	// result, if no framerate is given then 60fps is used
+	arg := "mi_mode=mci:mc_mode=aobmc:vsbmc=1"
+	if sf.Framerate > 0 {
+		arg = fmt.Sprintf("fps=%s:%s", strconv.FormatFloat(sf.Framerate, 'f', -1, 64), arg)
+	}
+	return "minterpolate", arg
+}
+
+// Scale converts a duration of the input into the
+// duration it lasts for after the speed change
+func (sf *SpeedFilter) Scale(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / sf.Factor)
+}
+
+// Effect is a playback effect applied to the video
+type Effect int
+
+const (
+	NoEffect Effect = iota
+	Reverse
+	Boomerang
+)
+
+// EffectFilter plays the video reversed or as a boomerang, i.e.
+// forwards then reversed, and repeats it. Reversing a video
+// buffers every frame in memory so the clip must be short
+type EffectFilter struct {
+	Effect    Effect
+	Repeat    int     // How many times the effect is played
+	Framerate float64 // Framerate of the input, needed to estimate the memory used
+	MaxMemory int64   // Most memory the buffered frames can use in bytes, 0 for no limit
+}
+
+func NewEffectFilter() *EffectFilter {
+	return &EffectFilter{
+		Effect:    NoEffect,
+		Repeat:    1,
+		Framerate: -1,
+		MaxMemory: 0,
+	}
+}
+
+func (ef *EffectFilter) Valid() bool {
+	return ef.Effect != NoEffect && ef.Repeat >= 1
+}
+
+// Scale converts the duration of the clip into
+// the duration it lasts for with the effect
+func (ef *EffectFilter) Scale(d time.Duration) time.Duration {
+	if ef.Effect == Boomerang {
+		d *= 2
+	}
+	return d * time.Duration(ef.Repeat)
+}
+
+// Memory estimates how many bytes of memory the effect needs for a
+// clip of width by height with n frames. The reverse filter buffers
+// the whole clip and repeating buffers the clip with its effect
+func (ef *EffectFilter) Memory(width, height int, n int64) int64 {
+	// Each yuv420p frame uses 12 bits per pixel
+	frameSize := int64(width) * int64(height) * 3 / 2
+
+	buffered := n
+	if ef.Repeat > 1 {
+		buffered += ef.frames(n)
+	}
+
+	return buffered * frameSize
+}
+
+// ValidLength returns whether a clip with n frames can be repeated,
+// the loop filter can only buffer up to 32767 frames
+func (ef *EffectFilter) ValidLength(n int64) bool {
+	return ef.Repeat == 1 || ef.frames(n) <= 32767
+}
+
+// frames returns how many frames are in the clip with the effect
+func (ef *EffectFilter) frames(n int64) int64 {
+	if ef.Effect == Boomerang {
+		return n * 2
+	}
+	return n
+}
+
+// ArgLoop returns the loop filter which repeats the clip, the loop
+// size is the maximum number of frames the loop filter can buffer
+func (ef *EffectFilter) ArgLoop() (string, string) {
+	return "loop", fmt.Sprintf("%d:32767:0", ef.Repeat-1)
+}
+
+func (ef *EffectFilter) ArgAudioLoop() (string, string) {
+	return "aloop", fmt.Sprintf("%d:2147483647:0", ef.Repeat-1)
+}
+
+// RotateFilter rotates the video clockwise
+type RotateFilter struct {
+	Degrees int // Either 90, 180 or 270
+}
+
+func NewRotateFilter() *RotateFilter {
+	return &RotateFilter{
+		Degrees: 0,
+	}
+}
+
+func (rf *RotateFilter) Valid() bool {
+	return rf.Degrees == 90 || rf.Degrees == 180 || rf.Degrees == 270
+}
+
+// Swaps returns whether the rotation swaps the width and height
+func (rf *RotateFilter) Swaps() bool {
+	return rf.Degrees == 90 || rf.Degrees == 270
+}
+
+func (rf *RotateFilter) Args() [][]string {
+	switch rf.Degrees {
+	case 90:
+		return [][]string{{"transpose", "clock"}}
+	case 180:
+		return [][]string{{"hflip", ""}, {"vflip", ""}}
+	case 270:
+		return [][]string{{"transpose", "cclock"}}
+	}
+	return nil
+}
+
+// FlipFilter mirrors the video horizontally and/or vertically
+type FlipFilter struct {
+	Horizontal, Vertical bool
+}
+
+func NewFlipFilter() *FlipFilter {
+	return &FlipFilter{
+		Horizontal: false,
+		Vertical:   false,
+	}
+}
+
+func (ff *FlipFilter) Valid() bool {
+	return ff.Horizontal || ff.Vertical
+}
+
+func (ff *FlipFilter) Args() [][]string {
+	args := make([][]string, 0)
+	if ff.Horizontal {
+		args = append(args, []string{"hflip", ""})
+	}
+	if ff.Vertical {
+		args = append(args, []string{"vflip", ""})
+	}
+	return args
+}
+
 // DeinterlaceFilter deinterlaces the video
 type DeinterlaceFilter struct{}
 
@@ -164,6 +359,52 @@ func (df *DenoiseFilter) Args() (string, string) {
 	return "hqdn3d", "4.0:3.0:6.0:4.5"
 }
 
+// FadeFilter fades the video from and to black and the audio
+// from and to silence at the start and end of the output
+type FadeFilter struct {
+	In       float64 // Length of the fade in, in seconds
+	Out      float64 // Length of the fade out, in seconds
+	Duration float64 // Duration of the output, needed to work out when the fade out starts
+}
+
+func NewFadeFilter() *FadeFilter {
+	return &FadeFilter{
+		In:       0,
+		Out:      0,
+		Duration: -1,
+	}
+}
+
+func (ff *FadeFilter) Valid() bool {
+	if ff.In < 0 || ff.Out < 0 || ff.In+ff.Out == 0 {
+		return false
+	}
+	return ff.Out == 0 || (ff.Duration > 0 && ff.In+ff.Out <= ff.Duration)
+}
+
+func (ff *FadeFilter) outStart() string {
+	return strconv.FormatFloat(ff.Duration-ff.Out, 'f', -1, 64)
+}
+
+func (ff *FadeFilter) Args() [][]string {
+	args := make([][]string, 0)
+	if ff.In > 0 {
+		args = append(args, []string{"fade", fmt.Sprintf("t=in:st=0:d=%s", strconv.FormatFloat(ff.In, 'f', -1, 64))})
+	}
+	if ff.Out > 0 {
+		args = append(args, []string{"fade", fmt.Sprintf("t=out:st=%s:d=%s", ff.outStart(), strconv.FormatFloat(ff.Out, 'f', -1, 64))})
+	}
+	return args
+}
+
+func (ff *FadeFilter) AudioArgs() [][]string {
+	args := make([][]string, 0)
+	for _, pair := range ff.Args() {
+		args = append(args, []string{"afade", pair[1]})
+	}
+	return args
+}
+
 // DubLoopMode If looping with the dub filter, this
 // specifies whether the audio or video should be looped
 type DubLoopMode int
@@ -174,13 +415,21 @@ const (
 	Audio
 )
 
-// DubFilter dubs a video with audio from a file
+// DubFilter dubs a video with audio from a file, the audio
+// either replaces the original audio or is mixed into it
 type DubFilter struct {
 	Filepath      string  // Filepath to the file
 	VideoDuration float64 // Duration of the original video
-	AudioDuration float64 // Duration of the dubbed audio
+	AudioDuration float64 // Duration of the dubbed file
 	Loop          bool    // Loop the video/audio to achieve the full video length
 	Shortest      bool    // Include -shortest, automatically applied if looping
+	Mix           bool    // Mix the dubbed audio with the original audio instead of replacing it
+	Volume        float64 // Volume of the dubbed audio, 1 leaves it unchanged
+	SourceVolume  float64 // Volume of the original audio when mixing
+	Duck          bool    // Lowers the dubbed audio while the original audio is loud, i.e. music under speech
+	Delay         float64 // Seconds into the video the dubbed audio starts
+	Start         string  // Where to start reading the dubbed file, accepts "HH:MM:SS.MS/HH:MM:SS/S"
+	End           string  // Where to stop reading the dubbed file, accepts "HH:MM:SS.MS/HH:MM:SS/S"
 }
 
 func NewDubFilter() *DubFilter {
@@ -190,22 +439,102 @@ func NewDubFilter() *DubFilter {
 		AudioDuration: -1,
 		Loop:          false,
 		Shortest:      false,
+		Mix:           false,
+		Volume:        1,
+		SourceVolume:  1,
+		Duck:          false,
+		Delay:         0,
+		Start:         "",
+		End:           "",
 	}
 }
 
 func (df *DubFilter) Valid() bool {
-	return df.Filepath != "" && df.AudioDuration > 0 && df.VideoDuration >= 0
+	if df.Filepath == "" || df.AudioDuration <= 0 || df.VideoDuration < 0 {
+		return false
+	}
+	start, end, err := df.bounds()
+	if err != nil || start >= end {
+		return false
+	}
+	return df.Volume >= 0 && df.SourceVolume >= 0 && df.Delay >= 0
+}
+
+// bounds returns the start and end in seconds of the range which
+// is read from the dubbed file, the end is clamped to the file
+func (df *DubFilter) bounds() (float64, float64, error) {
+	start, end := 0.0, df.AudioDuration
+	if df.Start != "" {
+		s, err := parseTrimTime(df.Start)
+		if err != nil {
+			return 0, 0, err
+		}
+		start = s.Seconds()
+	}
+	if df.End != "" {
+		e, err := parseTrimTime(df.End)
+		if err != nil {
+			return 0, 0, err
+		}
+		end = math.Min(e.Seconds(), df.AudioDuration)
+	}
+	return start, end, nil
+}
+
+// EffectiveDuration returns how long the dubbed audio lasts in the output,
+// the range which is read from the file plus the delay before it starts
+func (df *DubFilter) EffectiveDuration() float64 {
+	start, end, err := df.bounds()
+	if err != nil {
+		return 0
+	}
+	return end - start + df.Delay
+}
+
+// SeekArgs returns the input options which read only the
+// range of the dubbed file, they're placed before its "-i"
+func (df *DubFilter) SeekArgs() [][]string {
+	start, end, err := df.bounds()
+	if err != nil {
+		return nil
+	}
+
+	args := make([][]string, 0)
+	if df.Start != "" {
+		args = append(args, []string{"-ss", strconv.FormatFloat(start, 'f', -1, 64)})
+	}
+	if df.End != "" {
+		args = append(args, []string{"-to", strconv.FormatFloat(end, 'f', -1, 64)})
+	}
+	return args
 }
 
 func (df *DubFilter) LoopMode() DubLoopMode {
-	if df.VideoDuration > df.AudioDuration {
+	audio := df.EffectiveDuration()
+	if df.VideoDuration > audio {
 		return Audio
-	} else if df.AudioDuration > df.VideoDuration {
+	} else if audio > df.VideoDuration {
 		return Video
 	}
 	return None
 }
 
+// Duration returns the duration of the output once the
+// video and audio have been looped or cut to the same length
+func (df *DubFilter) Duration() float64 {
+	audio := df.EffectiveDuration()
+	switch df.LoopMode() {
+	case Audio:
+		return df.VideoDuration
+	case Video:
+		return audio
+	}
+	if df.Shortest {
+		return math.Min(df.VideoDuration, audio)
+	}
+	return math.Max(df.VideoDuration, audio)
+}
+
 func (df *DubFilter) ArgLoop() (string, string) {
 	if df.LoopMode() == Audio {
 		return "aloop", "-1:2147483647:0"
@@ -215,6 +544,38 @@ func (df *DubFilter) ArgLoop() (string, string) {
 	return "", ""
 }
 
+func (df *DubFilter) ArgVolume() (string, string) {
+	return "volume", strconv.FormatFloat(df.Volume, 'f', -1, 64)
+}
+
+func (df *DubFilter) ArgSourceVolume() (string, string) {
+	return "volume", strconv.FormatFloat(df.SourceVolume, 'f', -1, 64)
+}
+
+func (df *DubFilter) ArgDelay() (string, string) {
+	return "adelay", fmt.Sprintf("delays=%d:all=1", int64(math.Round(df.Delay*1000)))
+}
+
+// ArgMix mixes the original audio with the dubbed audio, the inputs
+// aren't normalised so each keeps the volume it was given
+func (df *DubFilter) ArgMix() (string, string) {
+	// When the dubbed audio loops it never ends
+	// so the mix follows the original audio
+	duration := "longest"
+	if df.Shortest {
+		duration = "shortest"
+	} else if df.LoopMode() == Audio {
+		duration = "first"
+	}
+	return "amix", fmt.Sprintf("inputs=2:duration=%s:dropout_transition=0:normalize=0", duration)
+}
+
+// ArgDuck compresses the dubbed audio using the
+// original audio as the sidechain
+func (df *DubFilter) ArgDuck() (string, string) {
+	return "sidechaincompress", "threshold=0.03:ratio=8:attack=20:release=400"
+}
+
 func (df *DubFilter) ArgShortest() (string, string) {
 	return "-shortest", ""
 }
@@ -0,0 +1,58 @@
+package ffmpeg
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSpeedFilterAudioArgs(t *testing.T) {
+	tests := []struct {
+		factor float64
+		tempos []string
+	}{
+		{1.5, []string{"1.5"}},
+		{0.75, []string{"0.75"}},
+		{2, []string{"2"}},
+		{0.5, []string{"0.5"}},
+		{3, []string{"2", "1.5"}},
+		{8, []string{"2", "2", "2"}},
+		{0.25, []string{"0.5", "0.5"}},
+		{0.1, []string{"0.5", "0.5", "0.5", "0.8"}},
+		{100, []string{"2", "2", "2", "2", "2", "2", "1.5625"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.FormatFloat(tt.factor, 'f', -1, 64), func(t *testing.T) {
+			sf := NewSpeedFilter()
+			sf.Factor = tt.factor
+
+			args := sf.AudioArgs()
+			tempos := make([]string, 0, len(args))
+			product := 1.0
+			for _, arg := range args {
+				if arg[0] != "atempo" {
+					t.Fatalf("filter is %s, expected atempo", arg[0])
+				}
+				f, err := strconv.ParseFloat(arg[1], 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Each atempo filter only accepts a factor between 0.5 and 2
+				if f < 0.5 || f > 2 {
+					t.Errorf("atempo factor %s is out of range", arg[1])
+				}
+				tempos = append(tempos, arg[1])
+				product *= f
+			}
+
+			if strings.Join(tempos, ",") != strings.Join(tt.tempos, ",") {
+				t.Errorf("tempos are %v, expected %v", tempos, tt.tempos)
+			}
+			if math.Abs(product-tt.factor) > 1e-9 {
+				t.Errorf("tempos change the speed by %v, expected %v", product, tt.factor)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format is the container and codec the output is encoded as
+type Format int
+
+const (
+	WebM Format = iota
+	GIF
+	WebP // Animated WebP
+	AVIF // Animated AVIF, encoded with AV1
+)
+
+func (f Format) String() string {
+	return [...]string{"webm", "gif", "webp", "avif"}[f]
+}
+
+// FormatFromExt returns the format of a filepath from its
+// extension, unknown extensions are assumed to be WebM
+func FormatFromExt(fp string) Format {
+	switch strings.ToLower(filepath.Ext(fp)) {
+	case ".gif":
+		return GIF
+	case ".webp":
+		return WebP
+	case ".avif":
+		return AVIF
+	}
+	return WebM
+}
+
+// Animated returns whether the format is an animated image, they
+// have no audio and GIF and WebP aren't encoded with a video codec
+func (f Format) Animated() bool {
+	return f != WebM
+}
+
+// TwoPass returns whether the format's encoder can use two passes
+func (f Format) TwoPass() bool {
+	return f == WebM || f == AVIF
+}
+
+// AnimationArgs tunes the animated image formats
+type AnimationArgs struct {
+	Framerate  float64 // Framerate of a GIF, browsers slow down GIFs above 50fps
+	Colors     int     // Most colours in the GIF's palette, up to 256
+	Dither     string  // How the GIF is dithered i.e. "bayer/floyd_steinberg/sierra2_4a/none"
+	BayerScale int     // Scale of the bayer pattern from 0 to 5, a lower scale has more visible dithering
+	Lossless   bool    // Encode the WebP losslessly
+	Plays      int     // Times the animation plays, 0 plays it forever
+}
+
+func NewAnimationArgs() *AnimationArgs {
+	return &AnimationArgs{
+		Framerate:  15,
+		Colors:     256,
+		Dither:     "sierra2_4a",
+		BayerScale: 3,
+		Lossless:   false,
+		Plays:      0,
+	}
+}
+
+func (aa *AnimationArgs) Valid() bool {
+	switch aa.Dither {
+	case "bayer", "floyd_steinberg", "sierra2_4a", "none":
+	default:
+		return false
+	}
+	return aa.Framerate > 0 && aa.Framerate <= 50 && aa.Colors >= 2 && aa.Colors <= 256 &&
+		aa.BayerScale >= 0 && aa.BayerScale <= 5 && aa.Plays >= 0
+}
+
+// GIFArgs returns the filters applied before the palette is generated
+func (aa *AnimationArgs) GIFArgs(framerate float64) [][]string {
+	if framerate <= 0 {
+		framerate = aa.Framerate
+	}
+	return [][]string{{"fps", strconv.FormatFloat(framerate, 'f', -1, 64)}}
+}
+
+// ArgPaletteGen generates a palette for the whole GIF, only the
+// pixels which change are counted so moving parts get more colours
+func (aa *AnimationArgs) ArgPaletteGen() (string, string) {
+	return "palettegen", fmt.Sprintf("max_colors=%d:stats_mode=diff", aa.Colors)
+}
+
+// ArgPaletteUse maps each frame onto the palette, only the
+// rectangle which changed is redrawn to keep the GIF small
+func (aa *AnimationArgs) ArgPaletteUse() (string, string) {
+	arg := "dither=" + aa.Dither
+	if aa.Dither == "bayer" {
+		arg += fmt.Sprintf(":bayer_scale=%d", aa.BayerScale)
+	}
+	return "paletteuse", arg + ":diff_mode=rectangle"
+}
+
+// ArgLoop returns the muxer's loop option, GIF counts the
+// times it repeats whereas WebP counts the times it plays
+func (aa *AnimationArgs) ArgLoop(f Format) (string, string) {
+	loops := aa.Plays
+	if f == GIF && aa.Plays > 0 {
+		loops = aa.Plays - 1
+		if loops == 0 {
+			loops = -1
+		}
+	}
+	return "-loop", strconv.Itoa(loops)
+}
+
+// ArgPixelFormat returns the pixel format of the animation, a lossless
+// WebP is encoded from RGB so its chroma isn't subsampled beforehand
+func (aa *AnimationArgs) ArgPixelFormat(f Format) (string, string) {
+	if f == WebP && aa.Lossless {
+		return "-pix_fmt", "bgra"
+	}
+	return "-pix_fmt", "yuv420p"
+}
+
+// WebPArgs returns the encoder options for an animated WebP, the
+// quality is taken from the CRF so they share the same scale
+func (aa *AnimationArgs) WebPArgs(crf int) [][]string {
+	quality := 100 - crf*100/63
+	args := [][]string{
+		{"-c:v", "libwebp_anim"},
+		{"-quality", strconv.Itoa(quality)},
+		{"-compression_level", "6"},
+	}
+	if aa.Lossless {
+		args = append(args, []string{"-lossless", "1"})
+	}
+	return args
+}
@@ -2,7 +2,11 @@ package ffmpeg
 
 import (
 	"fmt"
+	"math"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Resources:
@@ -19,7 +23,10 @@ import (
 // If you don't want to include a command then set its
 // value to -1 or nil if it's a pointer
 type Inputs struct {
-	c *Command
+	c             *Command
+	dubInput      int   // Index of the dubbed file's input in the command
+	overlayInputs []int // Index of each overlay's input in the command
+	coverInput    int   // Index of the music video's cover image in the command
 
 	// Input
 	InputFp  string
@@ -36,19 +43,67 @@ type Inputs struct {
 	Framerate         float64    // Output framerate of the final video
 	TwoPass           bool
 
+	// Remux the input without re-encoding it, if
+	// set then the filters must all be nil
+	Copy *StreamCopy
+
+	// Writes a poster image of the output next to it, taken
+	// at "HH:MM:SS.MS/HH:MM:SS/S" or "best" for the best frame
+	Poster string
+
+	// Format of the output, the animated image formats
+	// are tuned by the animation args and have no audio
+	Format    Format
+	Animation *AnimationArgs
+
+	// Only outputs the audio, the output is encoded with Opus in a
+	// single pass and the video filters can't be used
+	AudioOnly bool
+
+	// Tunes the Opus encoder, only used when the audio is Opus
+	Opus *OpusArgs
+
+	// Channels of the output's audio
+	Channels *ChannelFilter
+
+	// Turns an audio file into a video with a cover
+	// image or a visualiser, the input is the audio
+	Music *MusicVideo
+
+	// Encodes a ladder of WebMs for adaptive streaming, the
+	// output is the manifest and the WebMs are written next to it
+	Dash *DashLadder
+
+	// Further outputs encoded from the same decode as the output
+	Variants []*Variant
+
 	// Args for variable encoding
 	VarArgs *VariableArgs
 
 	// Filter options
 	Dub         *DubFilter
 	Crop        *CropFilter
+	Rotate      *RotateFilter
+	Flip        *FlipFilter
 	Trim        *TrimFilter
+	Cuts        *CutList
+	Speed       *SpeedFilter
+	Effect      *EffectFilter
 	Resize      *ResizeFilter
+	Pad         *PadFilter
+	Captions    *CaptionFilter
+	Overlays    []*OverlayFilter
+	Fade        *FadeFilter
 	Denoise     *DenoiseFilter
 	Deinterlace *DeinterlaceFilter
 
-	// Dimensions
-	Width, Height int
+	// Dimensions of the input in display orientation, i.e.
+	// after any rotation in its metadata has been applied
+	Width, Height     int
+	SampleAspectRatio float64 // Width of the input's pixels relative to their height
+
+	// Duration of the input video in seconds
+	VideoDuration float64
 }
 
 func NewInputs() *Inputs {
@@ -64,61 +119,187 @@ func NewInputs() *Inputs {
 		VarArgs:           NewVariableArgs(),
 		Dub:               NewDubFilter(),
 		Crop:              NewCropFilter(),
+		Rotate:            nil,
+		Flip:              nil,
 		Trim:              NewTrimFilter(),
+		Cuts:              nil,
+		Speed:             nil,
+		Effect:            nil,
 		Resize:            NewResizeFilter(),
+		Pad:               nil,
+		Captions:          nil,
+		Overlays:          nil,
+		Fade:              nil,
 		Denoise:           nil,
 		Deinterlace:       nil,
 		Width:             -1,
 		Height:            -1,
+		SampleAspectRatio: 1,
+		VideoDuration:     -1,
 		TwoPass:           false,
+		Copy:              nil,
+		Music:             nil,
+		Poster:            "",
+		Format:            WebM,
+		Animation:         NewAnimationArgs(),
+		AudioOnly:         false,
+		Opus:              nil,
+		Channels:          NewChannelFilter(),
+		Dash:              nil,
+		Variants:          nil,
+	}
+}
+
+// Commands returns every command needed to encode the inputs, this is
+// a single command unless the cut list is written to separate files
+// or a DASH ladder is encoded
+func (i *Inputs) Commands() ([]*Command, error) {
+	if i.Dash != nil {
+		return i.dashCommands()
+	}
+
+	if !i.cutting() || !i.Cuts.Separate {
+		c, err := i.Command()
+		if err != nil {
+			return nil, err
+		}
+		return i.withPoster(c)
+	}
+
+	// Every segment would write its variants to the same files
+	if len(i.Variants) > 0 {
+		return nil, ErrVariantOutput
+	}
+
+	cmds := make([]*Command, 0, len(i.Cuts.Segments))
+	for n, seg := range i.Cuts.Segments {
+		si := i.clone()
+		si.Cuts = nil
+		si.Trim = &TrimFilter{Start: seg.Start, End: seg.End, VideoDuration: seg.VideoDuration}
+		si.OutputFp = SegmentFp(i.OutputFp, n)
+
+		c, err := si.Command()
+		if err != nil {
+			return nil, err
+		}
+		cs, err := si.withPoster(c)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cs...)
 	}
+
+	return cmds, nil
+}
+
+// withPoster follows the command with one which takes a poster
+// from its output, so the poster has every filter applied
+func (i *Inputs) withPoster(c *Command) ([]*Command, error) {
+	if i.Poster == "" {
+		return []*Command{c}, nil
+	}
+	if i.AudioOnly {
+		return nil, ErrThumbsInput
+	}
+
+	d, err := i.OutputDuration()
+	if err != nil {
+		return nil, err
+	}
+	t := NewThumbnails()
+	t.InputFp = i.OutputFp
+	t.Duration = d.Seconds()
+	t.Width, t.Height = i.OutputDimensions()
+	t.Poster = i.Poster
+	t.PosterFp = PosterFp(i.OutputFp)
+
+	poster, err := t.Commands()
+	if err != nil {
+		return nil, err
+	}
+	return append([]*Command{c}, poster...), nil
 }
 
 func (i *Inputs) Command() (*Command, error) {
-	i.VarArgs.codec = i.Codec
+	if i.Copy != nil {
+		return i.copyCommand()
+	}
 
 	if err := i.preprocess(); err != nil {
 		return nil, err
 	}
 
 	i.c = newCommand()
+	if d, err := i.OutputDuration(); err == nil && d > 0 {
+		i.c.duration = d
+	}
 
 	// Input args
-	i.c.twoPass = i.TwoPass
-	i.c.inputFp = i.InputFp
+	i.c.twoPass = i.TwoPass && !i.stillMusic() && !i.AudioOnly && i.Format.TwoPass()
+	i.c.addInput(i.InputFp)
 	i.c.outputFp = i.OutputFp
 	i.processDubInput()
+	i.processOverlayInputs()
+	i.processCoverInput()
 
 	// General Args
 	i.c.addGeneralArg("-metadata", fmt.Sprintf("title=\"%s\"", i.Title))
 	i.c.addGeneralArg("-threads", strconv.Itoa(i.Threads))
-	i.processFramerate()
-	i.c.addGeneralArg("-pix_fmt", "yuv420p")
-	i.c.addGeneralArg("-f", "webm")
+	if i.AudioOnly {
+		i.c.addGeneralArg("-vn", "")
+	} else {
+		i.processFramerate()
+		switch {
+		case i.Format == GIF:
+		case i.Format.Animated():
+			i.c.addGeneralArg(i.Animation.ArgPixelFormat(i.Format))
+		default:
+			i.c.addGeneralArg("-pix_fmt", "yuv420p")
+		}
+	}
+	i.c.addGeneralArg("-f", i.format())
+	if i.Format.Animated() {
+		i.c.addGeneralArg(i.Animation.ArgLoop(i.Format))
+	}
 	i.processDubShortest()
+	i.processMusicShortest()
 
 	// Map args
 	i.processMapStreams()
 
 	// Video filter args
-	i.processTrim()
+	if err := i.processTrim(); err != nil {
+		return nil, err
+	}
+	if err := i.processCuts(); err != nil {
+		return nil, err
+	}
+	i.processSpeed()
 	i.processCrop()
+	i.processRotate()
+	i.processFlip()
 	i.processDeinterlace()
 	i.processDenoise()
 	i.processResize()
+	i.processPad()
+	i.processEffect()
 	i.processDubLoop()
+	i.processOverlays()
+	i.processCaptions()
+	i.processFade()
+	i.processGIF()
+	if err := i.processVariants(); err != nil {
+		return nil, err
+	}
 
 	// Video Args
-	i.processVideoCodecAndModeArg()
-	i.processSlices()
-	i.c.addVideoArgs(i.Codec.ArgRowMT(i.RowMultithreading))
-	i.c.addVideoArg("-auto-alt-ref", "1")
-	i.c.addVideoArg("-g", "128")
-	i.c.addVideoArgs(i.Codec.ArgVideoCodecSpecific())
+	i.processVideoArgs()
 
 	// Audio args
 	i.processAudioCodec()
 
+	i.c.expect = i.expectation()
+
 	return i.c, nil
 }
 
@@ -136,19 +317,96 @@ func (i *Inputs) preprocess() error {
 		return ErrFramerate
 	}
 
+	if i.AudioOnly {
+		if err := i.preprocessAudioOnly(); err != nil {
+			return err
+		}
+	}
+	if i.Opus != nil && !i.Opus.Valid() {
+		return ErrOpus
+	}
+	if i.Channels != nil && !i.Channels.Valid() {
+		return ErrChannels
+	}
+
+	// Animated images have no audio and AVIF is always AV1
+	if i.Format.Animated() {
+		if i.AudioOnly {
+			return ErrFormatAudio
+		}
+		if i.Animation == nil || !i.Animation.Valid() {
+			return ErrAnimation
+		}
+		i.AudioEnabled = false
+		if i.Format == AVIF {
+			i.Codec = AV1
+		}
+	}
+	i.VarArgs.codec = i.Codec
+
+	if len(i.Variants) > 0 {
+		if err := i.preprocessVariants(); err != nil {
+			return err
+		}
+	}
+
+	// A music video's video is generated at its own framerate
+	if i.Music != nil {
+		if err := i.preprocessMusic(); err != nil {
+			return err
+		}
+	}
+
 	// Validate filter args
-	if i.Resize != nil && !i.Resize.ValidResolution() {
-		return ErrResize
+	if i.Resize != nil {
+		w, h := i.resizeSource()
+		if !i.Resize.ValidDimensions(w, h, i.sar()) {
+			return ErrResize
+		}
 	}
 	if i.Crop != nil && !i.Crop.ValidCrop() {
 		return ErrCrop
 	}
+	if i.Crop != nil && i.Width > 0 && i.Height > 0 && !i.Crop.ValidWithin(i.Width, i.Height) {
+		return ErrCrop
+	}
+	if i.Pad != nil {
+		w, h := i.padSource()
+		if !i.Pad.ValidDimensions(w, h) {
+			return ErrPad
+		}
+	}
+	for _, o := range i.Overlays {
+		if !o.Valid() {
+			return ErrOverlay
+		}
+	}
+	if i.Captions != nil && !i.Captions.Valid() {
+		return ErrCaption
+	}
+	if i.Rotate != nil && !i.Rotate.Valid() {
+		return ErrRotate
+	}
 	if i.Dub != nil && !i.Dub.Valid() {
 		return ErrDub
 	}
+	if i.Speed != nil && i.Speed.Factor <= 0 {
+		return ErrSpeed
+	}
+	if i.Cuts != nil {
+		if !i.Cuts.Valid() {
+			return ErrCutList
+		}
+		if i.Cuts.Separate {
+			return ErrCutSeparate
+		}
+		if i.trimming() {
+			return ErrCutTrim
+		}
+	}
 
 	// If trimming then video duration has changed, if dubbing then we need to update the video duration
-	if i.Trim != nil && i.Dub != nil {
+	if i.trimming() {
 		d, err := i.Trim.Duration()
 		if err != nil {
 			return err
@@ -156,9 +414,32 @@ func (i *Inputs) preprocess() error {
 		if d < 0 {
 			return ErrNegTrimDur
 		}
-
+	}
+	if i.Effect != nil && !i.Effect.Valid() {
+		return ErrEffect
+	}
+	if i.effecting() {
+		if err := i.validEffect(); err != nil {
+			return err
+		}
+	}
+	if i.Dub != nil && (i.trimming() || i.cutting() || i.speeding() || i.effecting()) {
+		d, err := i.Duration()
+		if err != nil {
+			return err
+		}
 		i.Dub.VideoDuration = d.Seconds()
 	}
+	if i.Fade != nil {
+		d, err := i.OutputDuration()
+		if err != nil {
+			return err
+		}
+		i.Fade.Duration = d.Seconds()
+		if !i.Fade.Valid() {
+			return ErrFade
+		}
+	}
 
 	// Validate mode arguments
 	if valid, err := i.VarArgs.Valid(); !valid {
@@ -180,22 +461,366 @@ func (i *Inputs) processDeinterlace() {
 	}
 }
 
-func (i *Inputs) processTrim() {
-	if i.Trim != nil && (i.Trim.ValidStart() || i.Trim.ValidEnd()) {
-		i.c.addVideoFilterArg("trim", i.Trim.FilterArg())
-		i.c.addVideoFilterArg("setpts", "PTS-STARTPTS")
+func (i *Inputs) processTrim() error {
+	if !i.trimming() {
+		return nil
+	}
+
+	if err := i.processTrimSeek(); err != nil {
+		return err
+	}
+
+	// Any leftover offset between the seek point and the first
+	// frame is removed so the output starts at zero. The dub
+	// input isn't seeked so its audio is left alone
+	i.c.addVideoFilterArg("setpts", "PTS-STARTPTS")
+	if !i.replacingAudio() {
+		i.c.addAudioFilterArg("asetpts", "PTS-STARTPTS")
+	}
+
+	return nil
+}
+
+func (i *Inputs) processTrimSeek() error {
+	if !i.trimming() {
+		return nil
+	}
+
+	// Seeking on the input means the frames before the start are never
+	// decoded, which is much quicker than trimming them with a filter
+	args, err := i.Trim.SeekArgs()
+	if err != nil {
+		return err
+	}
+	for _, pair := range args {
+		i.c.addInputSeekArg(pair[0], pair[1])
+	}
+
+	return nil
+}
+
+func (i *Inputs) processCuts() error {
+	if !i.cutting() {
+		return nil
+	}
+
+	// Seek to the earliest segment so nothing before it is decoded
+	start, end, err := i.Cuts.bounds()
+	if err != nil {
+		return err
+	}
+	if start > 0 {
+		i.c.addInputSeekArg("-ss", strconv.FormatFloat(start, 'f', -1, 64))
+	}
+	if end >= 0 {
+		i.c.addInputSeekArg("-to", strconv.FormatFloat(end, 'f', -1, 64))
+	}
+
+	// Each segment is trimmed out of the video and audio separately.
+	// The dubbed audio isn't cut since it's laid over the joined video.
+	// A filtered stream can only be read once so it's split for each
+	// segment, e.g. the visualiser or the looped cover of music
+	withVideo := i.c.hasVideo()
+	withAudio := i.c.hasAudio() && !i.replacingAudio()
+	segments := len(i.Cuts.Segments)
+	videos, audios := []*filterStream{i.c.video}, []*filterStream{i.c.audio}
+	if withVideo && segments > 1 {
+		videos = i.c.splitStream(i.c.video, "split", "v", segments)
+	}
+	if withAudio && segments > 1 {
+		audios = i.c.splitStream(i.c.audio, "asplit", "a", segments)
+	}
+
+	vLabels := make([]string, 0, segments)
+	aLabels := make([]string, 0, segments)
+	durations := make([]float64, 0, segments)
+	for n, seg := range i.Cuts.Segments {
+		arg, err := seg.segmentArg(start)
+		if err != nil {
+			return err
+		}
+		d, err := seg.Duration()
+		if err != nil {
+			return err
+		}
+		durations = append(durations, d.Seconds())
+
+		if withVideo {
+			v := i.c.newLabel("cv")
+			i.c.addFilterChain([]string{i.c.closeStream(videos[n], "v")}, []filter{{"trim", arg}, {"setpts", "PTS-STARTPTS"}}, []string{v})
+			vLabels = append(vLabels, v)
+		}
+		if withAudio {
+			a := i.c.newLabel("ca")
+			i.c.addFilterChain([]string{i.c.closeStream(audios[n], "a")}, []filter{{"atrim", arg}, {"asetpts", "PTS-STARTPTS"}}, []string{a})
+			aLabels = append(aLabels, a)
+		}
+	}
+
+	// Join the segments back together
+	var v, a string
+	if withVideo {
+		v = vLabels[0]
+	}
+	if withAudio {
+		a = aLabels[0]
+	}
+	if segments > 1 && i.Cuts.Crossfade > 0 {
+		// Crossfades are applied one join at a time, each starts
+		// its overlap before the end of the previous joined output
+		var offset float64
+		for n := 1; n < segments; n++ {
+			offset += durations[n-1] - i.Cuts.Crossfade
+
+			if withVideo {
+				xv := i.c.newLabel("xv")
+				xfade := fmt.Sprintf("transition=fade:duration=%s:offset=%s",
+					strconv.FormatFloat(i.Cuts.Crossfade, 'f', -1, 64), strconv.FormatFloat(offset, 'f', -1, 64))
+				i.c.addFilterChain([]string{v, vLabels[n]}, []filter{{"xfade", xfade}}, []string{xv})
+				v = xv
+			}
+			if withAudio {
+				xa := i.c.newLabel("xa")
+				acrossfade := fmt.Sprintf("d=%s", strconv.FormatFloat(i.Cuts.Crossfade, 'f', -1, 64))
+				i.c.addFilterChain([]string{a, aLabels[n]}, []filter{{"acrossfade", acrossfade}}, []string{xa})
+				a = xa
+			}
+		}
+	} else if segments > 1 {
+		// Concat takes the segments interleaved, i.e. [v0][a0][v1][a1]
+		inputs := make([]string, 0, len(vLabels)+len(aLabels))
+		for n := 0; n < segments; n++ {
+			if withVideo {
+				inputs = append(inputs, vLabels[n])
+			}
+			if withAudio {
+				inputs = append(inputs, aLabels[n])
+			}
+		}
+
+		outputs := make([]string, 0, 2)
+		videoStreams, audioStreams := 0, 0
+		if withVideo {
+			v = i.c.newLabel("jv")
+			outputs = append(outputs, v)
+			videoStreams = 1
+		}
+		if withAudio {
+			a = i.c.newLabel("ja")
+			outputs = append(outputs, a)
+			audioStreams = 1
+		}
+		concat := fmt.Sprintf("n=%d:v=%d:a=%d", segments, videoStreams, audioStreams)
+		i.c.addFilterChain(inputs, []filter{{"concat", concat}}, outputs)
+	}
+
+	if withVideo {
+		i.c.video.label = v
+	}
+	if withAudio {
+		i.c.audio.label = a
+	}
+
+	return nil
+}
+
+func (i *Inputs) processSpeed() {
+	if !i.speeding() {
+		return
+	}
+
+	i.c.addVideoFilterArg(i.Speed.Args())
+	if i.Speed.ValidInterpolation() {
+		i.c.addVideoFilterArg(i.Speed.ArgInterpolate())
+	}
+
+	// The dubbed audio is laid over the video
+	// after it's been sped up so it's left alone
+	if !i.replacingAudio() {
+		for _, pair := range i.Speed.AudioArgs() {
+			i.c.addAudioFilterArg(pair[0], pair[1])
+		}
+	}
+}
+
+func (i *Inputs) processEffect() {
+	if !i.effecting() {
+		return
+	}
+
+	// The dubbed audio is laid over the video
+	// afterwards so it doesn't have the effect
+	withAudio := i.c.hasAudio() && !i.replacingAudio()
+
+	switch i.Effect.Effect {
+	case Reverse:
+		i.c.addVideoFilterArg("reverse", "")
+		if withAudio {
+			i.c.addAudioFilterArg("areverse", "")
+		}
+	case Boomerang:
+		// The clip is split in two so one copy can be
+		// reversed and joined onto the end of the other
+		if i.c.hasVideo() {
+			video := i.c.closeVideo()
+			fwd, rev, revd, out := i.c.newLabel("bf"), i.c.newLabel("br"), i.c.newLabel("brr"), i.c.newLabel("bv")
+			i.c.addFilterChain([]string{video}, []filter{{"split", ""}}, []string{fwd, rev})
+			i.c.addFilterChain([]string{rev}, []filter{{"reverse", ""}}, []string{revd})
+			i.c.addFilterChain([]string{fwd, revd}, []filter{{"concat", "n=2:v=1:a=0"}}, []string{out})
+			i.c.video.label = out
+		}
+
+		if withAudio {
+			audio := i.c.closeAudio()
+			fwd, rev, revd, out := i.c.newLabel("baf"), i.c.newLabel("bar"), i.c.newLabel("barr"), i.c.newLabel("ba")
+			i.c.addFilterChain([]string{audio}, []filter{{"asplit", ""}}, []string{fwd, rev})
+			i.c.addFilterChain([]string{rev}, []filter{{"areverse", ""}}, []string{revd})
+			i.c.addFilterChain([]string{fwd, revd}, []filter{{"concat", "n=2:v=0:a=1"}}, []string{out})
+			i.c.audio.label = out
+		}
+	}
+
+	if i.Effect.Repeat > 1 {
+		i.c.addVideoFilterArg(i.Effect.ArgLoop())
+		if withAudio {
+			i.c.addAudioFilterArg(i.Effect.ArgAudioLoop())
+		}
+	}
+}
+
+func (i *Inputs) processPad() {
+	if i.Pad == nil || !i.Pad.Valid() {
+		return
+	}
+
+	w, h := i.padSource()
+	if !i.Pad.Blur {
+		for _, pair := range i.Pad.Args(w, h) {
+			i.c.addVideoFilterArg(pair[0], pair[1])
+		}
+		return
+	}
+
+	// The video is split so one copy can be blurred
+	// into the background for the other to sit on top
+	toFilters := func(args [][]string) []filter {
+		filters := make([]filter, 0, len(args))
+		for _, pair := range args {
+			filters = append(filters, filter{pair[0], pair[1]})
+		}
+		return filters
+	}
+
+	video := i.c.closeVideo()
+	fg, bg := i.c.newLabel("pf"), i.c.newLabel("pb")
+	fgs, bgs, out := i.c.newLabel("pfs"), i.c.newLabel("pbs"), i.c.newLabel("pv")
+	name, arg := i.Pad.ArgOverlay()
+	i.c.addFilterChain([]string{video}, []filter{{"split", ""}}, []string{fg, bg})
+	i.c.addFilterChain([]string{bg}, toFilters(i.Pad.BackgroundArgs(w, h)), []string{bgs})
+	i.c.addFilterChain([]string{fg}, toFilters(i.Pad.ForegroundArgs(w, h)), []string{fgs})
+	i.c.addFilterChain([]string{bgs, fgs}, []filter{{name, arg}}, []string{out})
+	i.c.video.label = out
+}
+
+func (i *Inputs) processOverlayInputs() {
+	i.overlayInputs = make([]int, 0, len(i.Overlays))
+	for _, o := range i.Overlays {
+		index := i.c.addInput(o.Filepath)
+		for _, pair := range o.InputArgs() {
+			i.c.addInputArg(index, pair[0], pair[1])
+		}
+		i.overlayInputs = append(i.overlayInputs, index)
+	}
+}
+
+func (i *Inputs) processOverlays() {
+	w, _ := i.OutputDimensions()
+
+	for n, o := range i.Overlays {
+		ov := fmt.Sprintf("%d:v:0", i.overlayInputs[n])
+		if args := o.Args(w); len(args) > 0 {
+			filters := make([]filter, 0)
+			for _, pair := range args {
+				filters = append(filters, filter{pair[0], pair[1]})
+			}
+			label := i.c.newLabel("ov")
+			i.c.addFilterChain([]string{ov}, filters, []string{label})
+			ov = label
+		}
+
+		video := i.c.closeVideo()
+		out := i.c.newLabel("ovv")
+		name, arg := o.ArgOverlay()
+		i.c.addFilterChain([]string{video, ov}, []filter{{name, arg}}, []string{out})
+		i.c.video.label = out
+	}
+}
 
-		if !i.usingDubFilter() {
-			i.c.addAudioFilterArg("atrim", i.Trim.FilterArg())
-			i.c.addAudioFilterArg("asetpts", "PTS-STARTPTS")
-			i.c.audioFilterInput = 0
+func (i *Inputs) processCaptions() {
+	if i.Captions != nil && i.Captions.Valid() {
+		_, h := i.OutputDimensions()
+		for _, pair := range i.Captions.Args(h) {
+			i.c.addVideoFilterArg(pair[0], pair[1])
 		}
 	}
 }
 
+// processGIF reduces the video to a palette of colours, the palette is
+// generated from a copy of the whole video before it's applied
+func (i *Inputs) processGIF() {
+	if i.Format != GIF {
+		return
+	}
+
+	for _, pair := range i.Animation.GIFArgs(i.Framerate) {
+		i.c.addVideoFilterArg(pair[0], pair[1])
+	}
+	video := i.c.closeVideo()
+	frames, palette, paletted, out := i.c.newLabel("gf"), i.c.newLabel("gp"), i.c.newLabel("gpp"), i.c.newLabel("gif")
+	i.c.addFilterChain([]string{video}, []filter{{"split", ""}}, []string{frames, palette})
+	name, arg := i.Animation.ArgPaletteGen()
+	i.c.addFilterChain([]string{palette}, []filter{{name, arg}}, []string{paletted})
+	name, arg = i.Animation.ArgPaletteUse()
+	i.c.addFilterChain([]string{frames, paletted}, []filter{{name, arg}}, []string{out})
+	i.c.video.label = out
+}
+
+// processFade is applied last so the fades sit at the
+// edges of the output after any trimming and looping
+func (i *Inputs) processFade() {
+	if i.Fade == nil {
+		return
+	}
+	for _, pair := range i.Fade.Args() {
+		i.c.addVideoFilterArg(pair[0], pair[1])
+	}
+	for _, pair := range i.Fade.AudioArgs() {
+		i.c.addAudioFilterArg(pair[0], pair[1])
+	}
+}
+
 func (i *Inputs) processResize() {
 	if i.Resize != nil && i.Resize.ValidResolution() {
-		i.c.addVideoFilterArg(i.Resize.Args())
+		w, h := i.resizeSource()
+		for _, pair := range i.Resize.Args(w, h, i.sar()) {
+			i.c.addVideoFilterArg(pair[0], pair[1])
+		}
+	}
+}
+
+func (i *Inputs) processRotate() {
+	if i.Rotate != nil && i.Rotate.Valid() {
+		for _, pair := range i.Rotate.Args() {
+			i.c.addVideoFilterArg(pair[0], pair[1])
+		}
+	}
+}
+
+func (i *Inputs) processFlip() {
+	if i.Flip != nil && i.Flip.Valid() {
+		for _, pair := range i.Flip.Args() {
+			i.c.addVideoFilterArg(pair[0], pair[1])
+		}
 	}
 }
 
@@ -206,33 +831,124 @@ func (i *Inputs) processCrop() {
 }
 
 func (i *Inputs) processMapStreams() {
-	// If no dubbing then we map the standard streams
-	if !i.usingDubFilter() {
-		i.c.addMapArgs("0:v:0")
-		if i.AudioEnabled && i.AudioTrack.Index > -1 {
-			i.c.addMapArgs("0:a:" + strconv.Itoa(i.AudioTrack.Index))
+	// The streams are mapped after they pass through the filtergraph,
+	// if looping while dubbing then the stream which is looped is
+	// mapped from the output of its loop filter
+	if i.Music != nil {
+		i.processMusicStreams()
+		return
+	}
+
+	if !i.AudioOnly {
+		i.c.setVideoSource("0:v:0")
+	}
+	if !i.AudioEnabled {
+		return
+	}
+
+	if i.replacingAudio() {
+		i.c.setAudioSource(fmt.Sprintf("%d:a:0", i.dubInput))
+	} else if i.AudioTrack.Index > -1 {
+		i.c.setAudioSource("0:a:" + strconv.Itoa(i.AudioTrack.Index))
+	}
+}
+
+func (i *Inputs) preprocessAudioOnly() error {
+	if !i.AudioEnabled || (i.AudioTrack.Index < 0 && i.Dub == nil) {
+		return ErrAudioOnly
+	}
+
+	// The video filters have nothing to filter
+	if i.Crop != nil || i.Rotate != nil || i.Flip != nil || i.Resize != nil || i.Pad != nil || i.Captions != nil || len(i.Overlays) > 0 || i.Denoise != nil || i.Deinterlace != nil || i.Music != nil {
+		return ErrAudioOnlyFilter
+	}
+
+	return nil
+}
+
+func (i *Inputs) preprocessMusic() error {
+	if !i.Music.Valid() {
+		return ErrMusic
+	}
+	if !i.AudioEnabled || i.AudioTrack.Index < 0 || i.Dub != nil {
+		return ErrMusicAudio
+	}
+	if i.Music.Still() && i.Music.CoverFp == "" && (i.Width <= 0 || i.Height <= 0) {
+		return ErrMusicCover
+	}
+	if i.Framerate < 0 {
+		i.Framerate = i.Music.Framerate
+	}
+
+	// The visualiser is the source of the video so the
+	// filters which follow need to know its dimensions
+	if !i.Music.Still() {
+		i.Width, i.Height = i.Music.Width, i.Music.Height
+		i.SampleAspectRatio = 1
+	}
+
+	return nil
+}
+
+func (i *Inputs) processCoverInput() {
+	if i.Music != nil && i.Music.Still() && i.Music.CoverFp != "" {
+		i.coverInput = i.c.addInput(i.Music.CoverFp)
+		for _, pair := range i.Music.CoverInputArgs() {
+			i.c.addInputArg(i.coverInput, pair[0], pair[1])
 		}
+	}
+}
 
+// processMusicStreams sets up the streams of a music video, the
+// video is either the cover image or a visualiser which is drawn
+// from a copy of the audio before it's filtered
+func (i *Inputs) processMusicStreams() {
+	audio := "0:a:" + strconv.Itoa(i.AudioTrack.Index)
+
+	switch {
+	case !i.Music.Still():
+		a, v := i.c.newLabel("ma"), i.c.newLabel("mv")
+		i.c.addFilterChain([]string{audio}, []filter{{"asplit", ""}}, []string{a, v})
+		name, arg := i.Music.ArgVisualiser()
+		vis := i.c.newLabel("vis")
+		i.c.addFilterChain([]string{v}, []filter{{name, arg}}, []string{vis})
+		i.c.setVideoSource(vis)
+		i.c.setAudioSource(a)
 		return
+	case i.Music.CoverFp != "":
+		i.c.setVideoSource(fmt.Sprintf("%d:v:0", i.coverInput))
+	default:
+		// The embedded cover art is a single frame so it's looped
+		i.c.setVideoSource("0:v:0")
+		for _, pair := range i.Music.EmbeddedArgs() {
+			i.c.addVideoFilterArg(pair[0], pair[1])
+		}
 	}
+	i.c.addVideoFilterArg(i.Music.ArgEven())
+	i.c.setAudioSource(audio)
+}
 
-	// Dubbing enabled, if looping we only map one stream
-	// because the other stream will be mapped with a
-	// video/audio filter
-	if i.Dub.LoopMode() == None {
-		i.c.addMapArgs("0:v:0")
-		i.c.addMapArgs("1:a")
-	} else if i.Dub.LoopMode() == Audio {
-		i.c.addMapArgs("0:v:0")
-	} else if i.Dub.LoopMode() == Video {
-		i.c.addMapArgs("1:a")
+// processMusicShortest ends the video with the audio
+// since the cover image is looped forever
+func (i *Inputs) processMusicShortest() {
+	if i.stillMusic() {
+		i.c.addGeneralArg("-shortest", "")
+	}
+}
+
+func (i *Inputs) processMusicCodec() {
+	if i.stillMusic() {
+		i.c.addVideoArg(i.Music.ArgGOP())
+		i.c.addVideoArgs(i.Codec.ArgStillImage())
 	}
 }
 
 func (i *Inputs) processDubInput() {
 	if i.usingDubFilter() {
-		i.c.audioFilterInput = 1
-		i.c.addInputArgs(i.Dub.Filepath)
+		i.dubInput = i.c.addInput(i.Dub.Filepath)
+		for _, pair := range i.Dub.SeekArgs() {
+			i.c.addInputArg(i.dubInput, pair[0], pair[1])
+		}
 	}
 }
 
@@ -243,16 +959,71 @@ func (i *Inputs) processDubShortest() {
 }
 
 func (i *Inputs) processDubLoop() {
-	if i.usingDubFilter() {
-		if i.Dub.LoopMode() == Audio {
-			i.c.addAudioFilterArg("asetpts", "PTS-STARTPTS")
-			i.c.addAudioFilterArg(i.Dub.ArgLoop())
-		} else if i.Dub.LoopMode() == Video {
-			i.c.addVideoFilterArg(i.Dub.ArgLoop())
-		}
+	if !i.usingDubFilter() {
+		return
+	}
+
+	if i.Dub.LoopMode() == Video {
+		i.c.addVideoFilterArg(i.Dub.ArgLoop())
+	}
+	if i.mixingDub() {
+		i.processDubMix()
+		return
+	}
+	if i.Dub.LoopMode() == Audio {
+		i.c.addAudioFilterArg("asetpts", "PTS-STARTPTS")
+		i.c.addAudioFilterArg(i.Dub.ArgLoop())
+	}
+	if i.Dub.Volume != 1 {
+		i.c.addAudioFilterArg(i.Dub.ArgVolume())
+	}
+	if i.Dub.Delay > 0 {
+		i.c.addAudioFilterArg(i.Dub.ArgDelay())
 	}
 }
 
+// processDubMix mixes the dubbed audio into the original audio. The
+// original audio has already been trimmed and had any effects applied
+// so the dubbed audio is laid over it as it sounds in the output
+func (i *Inputs) processDubMix() {
+	filters := []filter{{"asetpts", "PTS-STARTPTS"}}
+	if i.Dub.LoopMode() == Audio {
+		name, arg := i.Dub.ArgLoop()
+		filters = append(filters, filter{name, arg})
+	}
+	if i.Dub.Volume != 1 {
+		name, arg := i.Dub.ArgVolume()
+		filters = append(filters, filter{name, arg})
+	}
+	if i.Dub.Delay > 0 {
+		name, arg := i.Dub.ArgDelay()
+		filters = append(filters, filter{name, arg})
+	}
+	dub := i.c.newLabel("dub")
+	i.c.addFilterChain([]string{fmt.Sprintf("%d:a:0", i.dubInput)}, filters, []string{dub})
+
+	if i.Dub.SourceVolume != 1 {
+		i.c.addAudioFilterArg(i.Dub.ArgSourceVolume())
+	}
+	source := i.c.closeAudio()
+
+	// The sidechain is padded with silence so the
+	// dubbed audio isn't cut off when the original ends
+	if i.Dub.Duck {
+		src, sc, scp, ducked := i.c.newLabel("src"), i.c.newLabel("sc"), i.c.newLabel("scp"), i.c.newLabel("duck")
+		i.c.addFilterChain([]string{source}, []filter{{"asplit", ""}}, []string{src, sc})
+		i.c.addFilterChain([]string{sc}, []filter{{"apad", ""}}, []string{scp})
+		name, arg := i.Dub.ArgDuck()
+		i.c.addFilterChain([]string{dub, scp}, []filter{{name, arg}}, []string{ducked})
+		source, dub = src, ducked
+	}
+
+	out := i.c.newLabel("mix")
+	name, arg := i.Dub.ArgMix()
+	i.c.addFilterChain([]string{source, dub}, []filter{{name, arg}}, []string{out})
+	i.c.audio.label = out
+}
+
 func (i *Inputs) processFramerate() {
 	// Output framerate
 	if i.Framerate > -1 {
@@ -261,12 +1032,31 @@ func (i *Inputs) processFramerate() {
 }
 
 func (i *Inputs) processSlices() {
-	w := i.Width
-	h := i.Height
+	// Slices split up the encoded frames so they're
+	// chosen from the dimensions of the output
+	w, h := i.OutputDimensions()
 	i.Slices = RecommendedSlices(w, h)
 	i.c.addVideoArgs(i.Codec.ArgSlices(i.Slices, w, h, i.Threads))
 }
 
+func (i *Inputs) processVideoArgs() {
+	switch {
+	case i.AudioOnly:
+	case i.Format == GIF:
+		i.c.addVideoArg("-c:v", "gif")
+	case i.Format == WebP:
+		i.c.addVideoArgs(i.Animation.WebPArgs(i.VarArgs.CRF))
+	default:
+		i.processVideoCodecAndModeArg()
+		i.processSlices()
+		i.c.addVideoArgs(i.Codec.ArgRowMT(i.RowMultithreading))
+		i.c.addVideoArg("-auto-alt-ref", "1")
+		i.c.addVideoArg("-g", "128")
+		i.c.addVideoArgs(i.Codec.ArgVideoCodecSpecific())
+		i.processMusicCodec()
+	}
+}
+
 func (i *Inputs) processVideoCodecAndModeArg() {
 	// Which codec should ffmpeg use
 	// Quality: AV1 > VP9 > VP8
@@ -293,13 +1083,346 @@ func (i *Inputs) processAudioCodec() {
 	//  9: 320,
 	//  10: 500,
 
-	if i.AudioEnabled {
+	if !i.AudioEnabled {
+		return
+	}
+
+	if i.Channels != nil {
+		for _, pair := range i.Channels.Args() {
+			i.c.addAudioFilterArg(pair[0], pair[1])
+		}
+		i.c.addAudioArg(i.Channels.ArgChannels())
+	} else {
 		i.c.addAudioArg("-ac", "2") // 2 audio channels
+	}
+
+	// Audio only outputs are always Opus
+	if i.AudioOnly {
+		i.c.addAudioArg("-c:a", "libopus")
+	} else {
 		i.c.addAudioArg(i.Codec.ArgAudioCodec())
+	}
+
+	// Opus is given a bitrate for the number of channels whereas
+	// Vorbis' quality scale already accounts for them
+	if i.opus() {
+		bitrate := i.VarArgs.AudioBitrate
+		if i.Channels != nil {
+			bitrate = i.Channels.OpusBitrate(bitrate)
+			i.c.addAudioArgs(i.Channels.OpusArgs())
+		}
+		i.c.addAudioArg("-b:a", fmt.Sprintf("%dk", bitrate))
+	} else {
 		i.c.addAudioArg(i.VarArgs.ArgAudioQuality())
 	}
+	if i.Opus != nil && i.opus() {
+		i.c.addAudioArgs(i.Opus.Args())
+	}
+}
+
+// Duration returns how long the output video lasts for after it's
+// been trimmed, cut, had its speed changed and had its effect applied
+func (i *Inputs) Duration() (time.Duration, error) {
+	d, err := i.clipDuration()
+	if err != nil {
+		return 0, err
+	}
+
+	if i.effecting() {
+		d = i.Effect.Scale(d)
+	}
+
+	return d, nil
+}
+
+// OutputDuration returns the duration of the output, which
+// differs from Duration when dubbing loops or cuts the streams
+func (i *Inputs) OutputDuration() (time.Duration, error) {
+	d, err := i.Duration()
+	if err != nil {
+		return 0, err
+	}
+
+	if i.usingDubFilter() {
+		dub := *i.Dub
+		dub.VideoDuration = d.Seconds()
+		d = time.Duration(dub.Duration() * float64(time.Second))
+	}
+
+	return d, nil
+}
+
+// clipDuration returns the duration of the video before its effect
+func (i *Inputs) clipDuration() (time.Duration, error) {
+	var err error
+	d := time.Duration(i.VideoDuration * float64(time.Second))
+
+	if i.trimming() {
+		d, err = i.Trim.Duration()
+	} else if i.cutting() {
+		d, err = i.Cuts.Duration()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if i.speeding() {
+		d = i.Speed.Scale(d)
+	}
+
+	return d, nil
+}
+
+// OutputDimensions returns the width and height of the video
+// after it's been cropped, rotated, resized and padded
+func (i *Inputs) OutputDimensions() (int, int) {
+	w, h := i.padSource()
+
+	if i.Pad != nil && i.Pad.Valid() && w > 0 && h > 0 {
+		w, h = i.Pad.Dimensions(w, h)
+	}
+
+	return w, h
+}
+
+// padSource returns the width and height of the video
+// before it's padded, i.e. after it's resized
+func (i *Inputs) padSource() (int, int) {
+	w, h := i.resizeSource()
+
+	if i.Resize != nil && i.Resize.ValidResolution() {
+		w, h = i.Resize.Dimensions(w, h, i.sar())
+	}
+
+	return w, h
+}
+
+// resizeSource returns the width and height of the video
+// before it's resized, i.e. after it's cropped and rotated
+func (i *Inputs) resizeSource() (int, int) {
+	w, h := i.Width, i.Height
+
+	if i.Crop != nil && i.Crop.ValidCrop() {
+		w, h = i.Crop.W, i.Crop.H
+	}
+	if i.Rotate != nil && i.Rotate.Valid() && i.Rotate.Swaps() {
+		w, h = h, w
+	}
+
+	return w, h
+}
+
+// sar returns the sample aspect ratio of the video before it's resized
+func (i *Inputs) sar() float64 {
+	if i.SampleAspectRatio <= 0 {
+		return 1
+	}
+	if i.Rotate != nil && i.Rotate.Valid() && i.Rotate.Swaps() {
+		return 1 / i.SampleAspectRatio
+	}
+	return i.SampleAspectRatio
+}
+
+// validEffect checks the effect can buffer the frames it needs
+func (i *Inputs) validEffect() error {
+	d, err := i.clipDuration()
+	if err != nil {
+		return err
+	}
+
+	// Speeding the video up packs the frames closer together
+	// unless new frames are being interpolated between them
+	fps := i.Effect.Framerate
+	if fps <= 0 {
+		fps = 60
+	}
+	if i.speeding() && i.Speed.ValidInterpolation() {
+		fps = 60
+		if i.Speed.Framerate > 0 {
+			fps = i.Speed.Framerate
+		}
+	} else if i.speeding() {
+		fps *= i.Speed.Factor
+	}
+	frames := int64(math.Ceil(d.Seconds() * fps))
+
+	if !i.Effect.ValidLength(frames) {
+		return ErrEffectLength
+	}
+	w, h := i.OutputDimensions()
+	if i.Effect.MaxMemory > 0 && i.Effect.Memory(w, h, frames) > i.Effect.MaxMemory {
+		return ErrEffectMemory
+	}
+
+	return nil
+}
+
+func (i *Inputs) effecting() bool {
+	return i.Effect != nil && i.Effect.Valid()
+}
+
+func (i *Inputs) speeding() bool {
+	return i.Speed != nil && i.Speed.Valid()
+}
+
+func (i *Inputs) cutting() bool {
+	return i.Cuts != nil && len(i.Cuts.Segments) > 0
+}
+
+func (i *Inputs) trimming() bool {
+	return i.Trim != nil && (i.Trim.ValidStart() || i.Trim.ValidEnd())
+}
+
+// opus returns whether the audio is encoded with Opus
+func (i *Inputs) opus() bool {
+	_, codec := i.Codec.ArgAudioCodec()
+	return i.AudioOnly || codec == "libopus"
+}
+
+// format returns the container the output is muxed into, an
+// audio only output can also be written as an ogg/opus file
+func (i *Inputs) format() string {
+	if i.AudioOnly {
+		switch strings.ToLower(filepath.Ext(i.OutputFp)) {
+		case ".opus":
+			return "opus"
+		case ".ogg", ".oga":
+			return "ogg"
+		}
+	}
+	return i.Format.String()
+}
+
+// stillMusic returns whether the output is a music
+// video showing a still cover image
+func (i *Inputs) stillMusic() bool {
+	return i.Music != nil && i.Music.Still()
+}
+
+// mixingDub returns whether the dubbed audio is mixed into
+// the original audio, if there isn't any original audio
+// then the dubbed audio replaces it instead
+func (i *Inputs) mixingDub() bool {
+	return i.usingDubFilter() && i.Dub.Mix && i.AudioTrack.Index > -1
+}
+
+func (i *Inputs) replacingAudio() bool {
+	return i.usingDubFilter() && !i.mixingDub()
 }
 
 func (i *Inputs) usingDubFilter() bool {
 	return i.AudioEnabled && i.Dub != nil && i.Dub.Valid()
 }
+
+// clone returns a copy of the inputs which
+// doesn't share any of its filters
+func (i *Inputs) clone() *Inputs {
+	ci := *i
+	ci.c = nil
+
+	va := *i.VarArgs
+	ci.VarArgs = &va
+	if i.Copy != nil {
+		cp := *i.Copy
+		ci.Copy = &cp
+	}
+	if i.Dub != nil {
+		dub := *i.Dub
+		ci.Dub = &dub
+	}
+	if i.Crop != nil {
+		crop := *i.Crop
+		ci.Crop = &crop
+	}
+	if i.Trim != nil {
+		trim := *i.Trim
+		ci.Trim = &trim
+	}
+	if i.Cuts != nil {
+		cuts := *i.Cuts
+		cuts.Segments = make([]*TrimFilter, 0, len(i.Cuts.Segments))
+		for _, seg := range i.Cuts.Segments {
+			s := *seg
+			cuts.Segments = append(cuts.Segments, &s)
+		}
+		ci.Cuts = &cuts
+	}
+	if i.Resize != nil {
+		resize := *i.Resize
+		ci.Resize = &resize
+	}
+	if i.Pad != nil {
+		pad := *i.Pad
+		ci.Pad = &pad
+	}
+	if i.Overlays != nil {
+		ci.Overlays = make([]*OverlayFilter, 0, len(i.Overlays))
+		for _, o := range i.Overlays {
+			oc := *o
+			ci.Overlays = append(ci.Overlays, &oc)
+		}
+	}
+	if i.Animation != nil {
+		animation := *i.Animation
+		ci.Animation = &animation
+	}
+	if i.Channels != nil {
+		channels := *i.Channels
+		ci.Channels = &channels
+	}
+	if i.Opus != nil {
+		opus := *i.Opus
+		ci.Opus = &opus
+	}
+	if i.Music != nil {
+		music := *i.Music
+		ci.Music = &music
+	}
+	if i.Variants != nil {
+		ci.Variants = make([]*Variant, 0, len(i.Variants))
+		for _, v := range i.Variants {
+			vc := *v
+			if v.Resize != nil {
+				resize := *v.Resize
+				vc.Resize = &resize
+			}
+			ci.Variants = append(ci.Variants, &vc)
+		}
+	}
+	if i.Dash != nil {
+		dash := *i.Dash
+		dash.Rungs = append([]Rung(nil), i.Dash.Rungs...)
+		ci.Dash = &dash
+	}
+	if i.Fade != nil {
+		fade := *i.Fade
+		ci.Fade = &fade
+	}
+	if i.Captions != nil {
+		captions := *i.Captions
+		captions.Captions = make([]*Caption, 0, len(i.Captions.Captions))
+		for _, c := range i.Captions.Captions {
+			cc := *c
+			captions.Captions = append(captions.Captions, &cc)
+		}
+		ci.Captions = &captions
+	}
+	if i.Rotate != nil {
+		rotate := *i.Rotate
+		ci.Rotate = &rotate
+	}
+	if i.Flip != nil {
+		flip := *i.Flip
+		ci.Flip = &flip
+	}
+	if i.Speed != nil {
+		speed := *i.Speed
+		ci.Speed = &speed
+	}
+	if i.Effect != nil {
+		effect := *i.Effect
+		ci.Effect = &effect
+	}
+
+	return &ci
+}
@@ -0,0 +1,81 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every packet of the file is read so the time it may take grows
+// with the duration, it can take up to keyframesTimeout plus the
+// duration read at keyframesSpeed times realtime
+const (
+	keyframesTimeout = 30 * time.Second
+	keyframesSpeed   = 10
+)
+
+// Keyframes returns the timestamps in seconds of every keyframe
+// in the first video stream of a file which lasts duration seconds
+func Keyframes(fp string, duration float64) ([]float64, error) {
+	timeout := keyframesTimeout + time.Duration(duration/keyframesSpeed*float64(time.Second))
+	ctx, cancelFn := context.WithTimeout(context.Background(), timeout)
+	defer cancelFn()
+
+	// Reading the packets is much quicker than decoding the
+	// frames and still tells us which ones are keyframes
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags",
+		"-of", "csv=p=0",
+		fp,
+	)
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	kf := make([]float64, 0)
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		parts := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(parts) < 2 || !strings.Contains(parts[len(parts)-1], "K") {
+			continue
+		}
+
+		t, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			continue
+		}
+		kf = append(kf, t)
+	}
+
+	// Packets are listed in decode order
+	sort.Float64s(kf)
+
+	return kf, scanner.Err()
+}
+
+// nearestKeyframe returns the keyframe closest to t
+func nearestKeyframe(kf []float64, t float64) float64 {
+	nearest := kf[0]
+	for _, k := range kf {
+		if abs64(k-t) < abs64(nearest-t) {
+			nearest = k
+		}
+	}
+	return nearest
+}
+
+func abs64(a float64) float64 {
+	if a < 0 {
+		a *= -1
+	}
+	return a
+}
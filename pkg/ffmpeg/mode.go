@@ -10,6 +10,7 @@ type VariableArgs struct {
 	codec     Codec // Codec used
 	CRF       int   // CRF of the video
 	Tolerance int   // Tolerance for the CRF
+	Bitrate   int   // Most bitrate of the video in Kbps, 0 lets the CRF decide it
 	// Specify when using VP8!
 	AudioQualityScale int // Quality scale for the audio (-1 to 10)
 	// Specify when using VP9, AV1!
@@ -20,6 +21,7 @@ func NewVariableArgs() *VariableArgs {
 	return &VariableArgs{
 		CRF:               -1,
 		Tolerance:         -1,
+		Bitrate:           0,
 		AudioQualityScale: -1,
 		AudioBitrate:      -1,
 	}
@@ -42,6 +44,16 @@ func (va *VariableArgs) ArgAudioQuality() (string, string) {
 }
 
 func (va *VariableArgs) ArgVideoArgs() [][]string {
+	// A bitrate with the CRF caps the bitrate while keeping the quality,
+	// the quantizer isn't bounded around the CRF so the encoder can
+	// raise it as far as it needs to stay under the cap
+	if va.Bitrate > 0 {
+		return [][]string{
+			{"-crf", strconv.Itoa(va.CRF)},
+			{"-b:v", fmt.Sprintf("%dk", va.Bitrate)},
+		}
+	}
+
 	qMin := int(math.Max(0, float64(va.CRF-va.Tolerance)))
 	qMax := int(math.Min(63, float64(va.CRF+va.Tolerance)))
 
@@ -0,0 +1,99 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Visualiser renders the audio as the video of a music video
+type Visualiser int
+
+const (
+	NoVisualiser Visualiser = iota // A still cover image is shown instead
+	Waves
+	Spectrum
+	Vectorscope
+)
+
+// MusicVideo turns an audio file into a video, either by showing
+// a still cover image for the length of the audio or by rendering
+// a visualiser of the audio
+type MusicVideo struct {
+	CoverFp       string     // Filepath to the cover image, if empty the audio file's embedded cover art is used
+	Visualiser    Visualiser // Renders the audio instead of showing a cover image
+	Width, Height int        // Resolution of the visualiser
+	Framerate     float64    // Framerate of the output, a still image only needs a very low framerate
+}
+
+func NewMusicVideo() *MusicVideo {
+	return &MusicVideo{
+		CoverFp:    "",
+		Visualiser: NoVisualiser,
+		Width:      1280,
+		Height:     720,
+		Framerate:  1,
+	}
+}
+
+func (mv *MusicVideo) Valid() bool {
+	if mv.Framerate <= 0 {
+		return false
+	}
+	if mv.Still() {
+		return true
+	}
+	return mv.Width > 0 && mv.Height > 0 && mv.Width%2 == 0 && mv.Height%2 == 0 &&
+		mv.Width <= MaxDimension && mv.Height <= MaxDimension
+}
+
+// Still returns whether the video is a still image
+func (mv *MusicVideo) Still() bool {
+	return mv.Visualiser == NoVisualiser
+}
+
+// CoverInputArgs returns the options placed before the cover
+// image's input so it's read as a continuous stream of frames
+func (mv *MusicVideo) CoverInputArgs() [][]string {
+	return [][]string{
+		{"-loop", "1"},
+		{"-framerate", strconv.FormatFloat(mv.Framerate, 'f', -1, 64)},
+	}
+}
+
+// EmbeddedArgs returns the filters which turn the single frame
+// of embedded cover art into a stream at the video's framerate
+func (mv *MusicVideo) EmbeddedArgs() [][]string {
+	return [][]string{
+		{"loop", "-1:1:0"},
+		{"setpts", fmt.Sprintf("N/%s/TB", strconv.FormatFloat(mv.Framerate, 'f', -1, 64))},
+	}
+}
+
+// ArgEven rounds the cover down to even dimensions
+// which are needed when encoding with yuv420p
+func (mv *MusicVideo) ArgEven() (string, string) {
+	return "scale", "trunc(iw/2)*2:trunc(ih/2)*2"
+}
+
+func (mv *MusicVideo) ArgVisualiser() (string, string) {
+	size := fmt.Sprintf("s=%dx%d", mv.Width, mv.Height)
+	rate := strconv.FormatFloat(mv.Framerate, 'f', -1, 64)
+
+	switch mv.Visualiser {
+	case Waves:
+		return "showwaves", fmt.Sprintf("%s:mode=cline:rate=%s", size, rate)
+	case Spectrum:
+		return "showspectrum", fmt.Sprintf("%s:slide=scroll:color=intensity:fps=%s", size, rate)
+	case Vectorscope:
+		return "avectorscope", fmt.Sprintf("%s:rate=%s:zoom=1.5", size, rate)
+	}
+
+	return "", ""
+}
+
+// ArgGOP returns a keyframe interval of about ten seconds, a still
+// image barely changes so a long GOP costs almost nothing
+func (mv *MusicVideo) ArgGOP() (string, string) {
+	return "-g", strconv.Itoa(int(math.Max(1, math.Round(mv.Framerate*10))))
+}
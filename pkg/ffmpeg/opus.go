@@ -0,0 +1,62 @@
+package ffmpeg
+
+import "strconv"
+
+// Frame durations in milliseconds which libopus can encode
+var opusFrameDurations = []float64{2.5, 5, 10, 20, 40, 60, 80, 100, 120}
+
+// OpusArgs tunes the Opus encoder, options which are
+// left empty use the encoder's defaults
+type OpusArgs struct {
+	Application   string  // What the audio is tuned for i.e. "voip/audio/lowdelay"
+	VBR           string  // Bitrate mode i.e. "on/off/constrained"
+	FrameDuration float64 // Length of each frame in milliseconds, longer frames are more efficient at low bitrates
+}
+
+func NewOpusArgs() *OpusArgs {
+	return &OpusArgs{
+		Application:   "",
+		VBR:           "",
+		FrameDuration: 0,
+	}
+}
+
+func (oa *OpusArgs) Valid() bool {
+	switch oa.Application {
+	case "", "voip", "audio", "lowdelay":
+	default:
+		return false
+	}
+
+	switch oa.VBR {
+	case "", "on", "off", "constrained":
+	default:
+		return false
+	}
+
+	if oa.FrameDuration == 0 {
+		return true
+	}
+	for _, d := range opusFrameDurations {
+		if oa.FrameDuration == d {
+			return true
+		}
+	}
+	return false
+}
+
+func (oa *OpusArgs) Args() [][]string {
+	args := make([][]string, 0)
+
+	if oa.Application != "" {
+		args = append(args, []string{"-application", oa.Application})
+	}
+	if oa.VBR != "" {
+		args = append(args, []string{"-vbr", oa.VBR})
+	}
+	if oa.FrameDuration != 0 {
+		args = append(args, []string{"-frame_duration", strconv.FormatFloat(oa.FrameDuration, 'f', -1, 64)})
+	}
+
+	return args
+}
@@ -0,0 +1,147 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SuffixFp returns the filepath numbered so it doesn't write over
+// an existing file, i.e. "out.webm" becomes "out (1).webm"
+func SuffixFp(fp string, n int) string {
+	if n < 1 {
+		return fp
+	}
+	ext := filepath.Ext(fp)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(fp, ext), n, ext)
+}
+
+// tempFp creates an empty file next to fp which the output is written
+// to while it's encoded, it keeps the extension so ffmpeg can still
+// tell the format from it. Being in the same directory means it can
+// be renamed to fp without copying it. The file is created with the
+// mode fp would be created with, or the mode of fp if it exists, since
+// the mode is kept when ffmpeg writes to it and when it's renamed
+func tempFp(fp string) (string, error) {
+	dir, base := filepath.Split(fp)
+	if dir == "" {
+		dir = "."
+	}
+	ext := filepath.Ext(base)
+	prefix := "." + strings.TrimSuffix(base, ext) + "."
+
+	for n := 0; n < 10000; n++ {
+		name := filepath.Join(dir, prefix+strconv.FormatInt(time.Now().UnixNano()+int64(n), 36)+".part"+ext)
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(name)
+			return "", err
+		}
+
+		if fi, err := os.Stat(fp); err == nil {
+			if err := os.Chmod(name, fi.Mode().Perm()); err != nil {
+				os.Remove(name)
+				return "", err
+			}
+		}
+		return name, nil
+	}
+
+	return "", os.ErrExist
+}
+
+// writeFileAtomic writes the data to a temporary file then renames it
+// to fp, so fp is either left as it was or has all of the data
+func writeFileAtomic(fp string, data []byte) error {
+	tmp, err := tempFp(fp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fp)
+}
+
+// sidecar is a file written next to the output once it's encoded
+type sidecar struct {
+	fp   string
+	data []byte
+}
+
+// samePath returns whether two filepaths refer to the same file
+func samePath(a, b string) bool {
+	if fa, err := os.Stat(a); err == nil {
+		if fb, err := os.Stat(b); err == nil {
+			return os.SameFile(fa, fb)
+		}
+	}
+
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// ValidOutputs returns ErrOutputInput if the command or
+// one of its variants would write over one of its inputs
+func (c *Command) ValidOutputs() error {
+	for _, fp := range c.Outputs() {
+		for _, in := range c.inputs {
+			if samePath(fp, in.fp) {
+				return ErrOutputInput
+			}
+		}
+	}
+	return nil
+}
+
+// target returns where ffmpeg writes the output, while it's
+// encoded it's written to a temporary file next to it
+func (c *Command) target() string {
+	if c.writeFp != "" {
+		return c.writeFp
+	}
+	return c.outputFp
+}
+
+// WithSuffix returns a copy of the inputs whose outputs are
+// numbered with SuffixFp so they don't write over existing files
+func (i *Inputs) WithSuffix(n int) *Inputs {
+	ci := i.clone()
+	ci.OutputFp = SuffixFp(i.OutputFp, n)
+	for _, v := range ci.Variants {
+		v.OutputFp = SuffixFp(v.OutputFp, n)
+	}
+	return ci
+}
+
+// WithSuffix returns a copy of the thumbnails whose images are
+// numbered with SuffixFp so they don't write over existing files
+func (t *Thumbnails) WithSuffix(n int) *Thumbnails {
+	ct := *t
+	if t.PosterFp != "" {
+		ct.PosterFp = SuffixFp(t.PosterFp, n)
+	}
+	if t.SheetFp != "" {
+		ct.SheetFp = SuffixFp(t.SheetFp, n)
+	}
+	if t.SpriteFp != "" {
+		ct.SpriteFp = SuffixFp(t.SpriteFp, n)
+	}
+	return &ct
+}
@@ -0,0 +1,132 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Anchor is the corner or centre of the video an overlay is placed at
+type Anchor int
+
+const (
+	NoAnchor Anchor = iota // The overlay is placed at X and Y instead
+	TopLeft
+	TopRight
+	BottomLeft
+	BottomRight
+	Centre
+)
+
+// stillExtensions are the extensions of files which are overlaid as a still image
+var stillExtensions = []string{".png", ".jpg", ".jpeg", ".bmp", ".webp", ".tif", ".tiff"}
+
+// OverlayFilter overlays an image or video on top of the video, i.e.
+// a watermark or picture-in-picture. The overlay is read from its
+// own input which is added alongside any other inputs
+type OverlayFilter struct {
+	Filepath string  // Filepath to the image or video
+	Anchor   Anchor  // Where the overlay is placed, NoAnchor uses X and Y
+	X, Y     int     // Position in pixels of the overlay's top left corner when not anchored
+	Margin   int     // Distance in pixels between the overlay and the anchored edges
+	Scale    float64 // Width of the overlay relative to the width of the video, 0 keeps its size
+	Opacity  float64 // Opacity of the overlay from 0 (invisible) to 1 (opaque)
+	Start    string  // When the overlay appears, accepts "HH:MM:SS.MS/HH:MM:SS/S"
+	End      string  // When the overlay disappears, empty to show it until the end
+	Loop     bool    // Loop the overlay if it's a video which is shorter than the output
+}
+
+func NewOverlayFilter() *OverlayFilter {
+	return &OverlayFilter{
+		Filepath: "",
+		Anchor:   BottomRight,
+		X:        0,
+		Y:        0,
+		Margin:   16,
+		Scale:    0,
+		Opacity:  1,
+		Start:    "",
+		End:      "",
+		Loop:     false,
+	}
+}
+
+func (of *OverlayFilter) Valid() bool {
+	return of.Filepath != "" && of.Scale >= 0 && of.Opacity > 0 && of.Opacity <= 1 &&
+		of.Margin >= 0 && validWindow(of.Start, of.End)
+}
+
+// Still returns whether the overlay is a still image
+func (of *OverlayFilter) Still() bool {
+	ext := strings.ToLower(filepath.Ext(of.Filepath))
+	for _, e := range stillExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// InputArgs returns the options placed before the overlay's input
+func (of *OverlayFilter) InputArgs() [][]string {
+	if of.Loop && !of.Still() {
+		return [][]string{{"-stream_loop", "-1"}}
+	}
+	return nil
+}
+
+// Args returns the filters applied to the overlay before it's placed
+// on the video, width is the width of the video in pixels
+func (of *OverlayFilter) Args(width int) [][]string {
+	args := make([][]string, 0)
+
+	if of.Scale > 0 && width > 0 {
+		w := even(math.Max(2, float64(width)*of.Scale))
+		args = append(args, []string{"scale", fmt.Sprintf("%d:-2:flags=lanczos", w)})
+	}
+	if of.Opacity < 1 {
+		args = append(args, []string{"format", "rgba"})
+		args = append(args, []string{"colorchannelmixer", fmt.Sprintf("aa=%s", strconv.FormatFloat(of.Opacity, 'f', -1, 64))})
+	}
+	return args
+}
+
+// ArgOverlay returns the overlay filter which places the overlay on the video
+func (of *OverlayFilter) ArgOverlay() (string, string) {
+	m := strconv.Itoa(of.Margin)
+
+	var x, y string
+	switch of.Anchor {
+	case TopLeft:
+		x, y = m, m
+	case TopRight:
+		x, y = "W-w-"+m, m
+	case BottomLeft:
+		x, y = m, "H-h-"+m
+	case BottomRight:
+		x, y = "W-w-"+m, "H-h-"+m
+	case Centre:
+		x, y = "(W-w)/2", "(H-h)/2"
+	default:
+		x, y = strconv.Itoa(of.X), strconv.Itoa(of.Y)
+	}
+
+	// A still image is repeated for the whole video, a video
+	// stops being drawn once it ends unless it's looped
+	arg := fmt.Sprintf("x=%s:y=%s", x, y)
+	switch {
+	case of.Still():
+		arg += ":eof_action=repeat"
+	case of.Loop:
+		arg += ":shortest=1"
+	default:
+		arg += ":eof_action=pass"
+	}
+	if enable := enableWindow(of.Start, of.End); enable != "" {
+		arg += ":enable=" + escapeFilterArg(enable)
+	}
+
+	return "overlay", arg
+}
@@ -0,0 +1,134 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// PadFilter pads the video out to a target aspect ratio or resolution,
+// the padding is either a solid colour or a blurred copy of the video.
+// If padding to a resolution smaller than the video then the video is
+// shrunk to fit inside it first
+type PadFilter struct {
+	Aspect        float64 // Target aspect ratio i.e. 9/16, only used if Width and Height aren't set
+	Width, Height int     // Target resolution in pixels
+	Color         string  // Colour of the padding i.e. "black" or "#ff0000"
+	Blur          bool    // Fill the padding with a scaled and blurred copy of the video instead
+	X, Y          float64 // Position of the video within the padding from 0 (left/top) to 1 (right/bottom)
+}
+
+func NewPadFilter() *PadFilter {
+	return &PadFilter{
+		Aspect: 0,
+		Width:  0,
+		Height: 0,
+		Color:  "black",
+		Blur:   false,
+		X:      0.5,
+		Y:      0.5,
+	}
+}
+
+func (pf *PadFilter) Valid() bool {
+	if pf.X < 0 || pf.X > 1 || pf.Y < 0 || pf.Y > 1 {
+		return false
+	}
+	if pf.Width > 0 || pf.Height > 0 {
+		return pf.Width > 0 && pf.Height > 0
+	}
+	return pf.Aspect > 0
+}
+
+// ValidDimensions returns whether a video of srcW by srcH can be padded
+func (pf *PadFilter) ValidDimensions(srcW, srcH int) bool {
+	if !pf.Valid() || srcW <= 0 || srcH <= 0 {
+		return false
+	}
+
+	w, h := pf.Dimensions(srcW, srcH)
+	return w >= 2 && h >= 2 && w <= MaxDimension && h <= MaxDimension
+}
+
+// Dimensions returns the width and height of the padded video
+func (pf *PadFilter) Dimensions(srcW, srcH int) (int, int) {
+	if pf.Width > 0 && pf.Height > 0 {
+		return even(float64(pf.Width)), even(float64(pf.Height))
+	}
+
+	// Grow whichever edge is too short for the aspect ratio
+	w, h := float64(srcW), float64(srcH)
+	if w/h > pf.Aspect {
+		h = w / pf.Aspect
+	} else {
+		w = h * pf.Aspect
+	}
+
+	// Rounding to an even number mustn't shrink the padding below the video
+	return int(math.Max(float64(even(w)), float64(srcW+srcW%2))), int(math.Max(float64(even(h)), float64(srcH+srcH%2)))
+}
+
+// fit returns the dimensions the video is shrunk to so it fits in
+// the padding, if the video already fits then they're unchanged
+func (pf *PadFilter) fit(srcW, srcH int) (int, int) {
+	w, h := pf.Dimensions(srcW, srcH)
+	if srcW <= w && srcH <= h {
+		return srcW, srcH
+	}
+
+	s := math.Min(float64(w)/float64(srcW), float64(h)/float64(srcH))
+	return int(math.Min(float64(w), float64(even(float64(srcW)*s)))), int(math.Min(float64(h), float64(even(float64(srcH)*s))))
+}
+
+// position returns the expression for the offset of the video within the padding
+func (pf *PadFilter) position(outer, inner string, p float64) string {
+	return fmt.Sprintf("(%s-%s)*%s", outer, inner, strconv.FormatFloat(p, 'f', -1, 64))
+}
+
+// Args returns the filters which pad a video of srcW by srcH
+// with a solid colour, they're used when not blurring
+func (pf *PadFilter) Args(srcW, srcH int) [][]string {
+	w, h := pf.Dimensions(srcW, srcH)
+	fw, fh := pf.fit(srcW, srcH)
+
+	args := make([][]string, 0)
+	if fw != srcW || fh != srcH {
+		args = append(args, []string{"scale", fmt.Sprintf("%d:%d:flags=lanczos", fw, fh)})
+	}
+	args = append(args, []string{"pad", fmt.Sprintf("%d:%d:%s:%s:color=%s", w, h,
+		pf.position("ow", "iw", pf.X), pf.position("oh", "ih", pf.Y), escapeFilterArg(pf.Color))})
+	args = append(args, []string{"setsar", "1"})
+
+	return args
+}
+
+// BackgroundArgs returns the filters which turn the video into the
+// blurred background, it's scaled until it covers the padding
+func (pf *PadFilter) BackgroundArgs(srcW, srcH int) [][]string {
+	w, h := pf.Dimensions(srcW, srcH)
+	s := math.Max(float64(w)/float64(srcW), float64(h)/float64(srcH))
+	cw, ch := int(math.Max(float64(w), float64(even(float64(srcW)*s)))), int(math.Max(float64(h), float64(even(float64(srcH)*s))))
+
+	return [][]string{
+		{"scale", fmt.Sprintf("%d:%d:flags=bilinear", cw, ch)},
+		{"crop", fmt.Sprintf("%d:%d", w, h)},
+		{"gblur", "sigma=20"},
+		{"setsar", "1"},
+	}
+}
+
+// ForegroundArgs returns the filters which shrink the
+// video to fit on top of the blurred background
+func (pf *PadFilter) ForegroundArgs(srcW, srcH int) [][]string {
+	fw, fh := pf.fit(srcW, srcH)
+	return [][]string{
+		{"scale", fmt.Sprintf("%d:%d:flags=lanczos", fw, fh)},
+		{"setsar", "1"},
+	}
+}
+
+// ArgOverlay returns the overlay filter which places
+// the foreground video on top of the blurred background
+func (pf *PadFilter) ArgOverlay() (string, string) {
+	return "overlay", fmt.Sprintf("%s:%s", pf.position("W", "w", pf.X), pf.position("H", "h", pf.Y))
+}
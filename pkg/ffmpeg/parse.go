@@ -6,19 +6,26 @@ import (
 )
 
 func (i *Inputs) ParseCodec(c string) error {
+	codec, err := parseCodec(c)
+	if err != nil {
+		return err
+	}
+
+	i.Codec = codec
+	return nil
+}
+
+func parseCodec(c string) (Codec, error) {
 	switch strings.ToLower(c) {
 	case "vp8":
-		i.Codec = VP8
-		return nil
+		return VP8, nil
 	case "vp9":
-		i.Codec = VP9
-		return nil
+		return VP9, nil
 	case "av1":
-		i.Codec = AV1
-		return nil
+		return AV1, nil
 	}
 
-	return ErrInvalidCodec
+	return VP8, ErrInvalidCodec
 }
 
 func (i *Inputs) ParseCRF(crf int) error {
@@ -59,8 +66,59 @@ func (i *Inputs) ParseAudioBitrate(b int) error {
 	return nil
 }
 
+// ParseResize parses the resize target, it accepts "width:height" or
+// "widthxheight", "fit:WxH", "fill:WxH", "max:N" for the longest edge,
+// "N%" and named resolutions such as "720p"
 func (i *Inputs) ParseResize(s string) error {
-	parts := strings.Split(s, ":")
+	if i.Resize == nil {
+		i.Resize = NewResizeFilter()
+	}
+	return i.Resize.ParseResize(s)
+}
+
+// ParseResize parses the resize target in the same formats as Inputs.ParseResize
+func (rf *ResizeFilter) ParseResize(s string) error {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if short, ok := namedResolutions[s]; ok {
+		rf.Mode = ShortEdge
+		rf.Width = short
+		return nil
+	}
+
+	if strings.HasSuffix(s, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || p <= 0 {
+			return ErrResize
+		}
+		rf.Mode = Percent
+		rf.Percent = p
+		return nil
+	}
+
+	if strings.HasPrefix(s, "max:") {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "max:"))
+		if err != nil || n <= 0 {
+			return ErrResize
+		}
+		rf.Mode = LongEdge
+		rf.Width = n
+		return nil
+	}
+
+	mode := Exact
+	if strings.HasPrefix(s, "fit:") {
+		mode = Fit
+		s = strings.TrimPrefix(s, "fit:")
+	} else if strings.HasPrefix(s, "fill:") {
+		mode = Fill
+		s = strings.TrimPrefix(s, "fill:")
+	}
+
+	parts := strings.Split(s, "x")
+	if len(parts) != 2 && mode == Exact {
+		parts = strings.Split(s, ":")
+	}
 	if len(parts) != 2 {
 		return ErrResize
 	}
@@ -75,8 +133,9 @@ func (i *Inputs) ParseResize(s string) error {
 		return ErrResize
 	}
 
-	i.Resize.Width = w
-	i.Resize.Height = h
+	rf.Mode = mode
+	rf.Width = w
+	rf.Height = h
 
 	return nil
 }
@@ -110,4 +169,320 @@ func (i *Inputs) ParseCrop(s string) error {
 	i.Crop.H = h
 
 	return nil
-}
\ No newline at end of file
+}
+
+// ParseCuts parses a cut list in the format "start-end,start-end",
+// the times are in the same format as the TrimFilter
+func (i *Inputs) ParseCuts(s string, videoDuration float64) error {
+	cl := NewCutList()
+	for _, r := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(r), "-", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return ErrCutList
+		}
+		if _, err := parseTrimTime(parts[0]); err != nil {
+			return ErrCutList
+		}
+		if parts[1] != "" {
+			if _, err := parseTrimTime(parts[1]); err != nil {
+				return ErrCutList
+			}
+		}
+
+		cl.AddSegment(parts[0], parts[1], videoDuration)
+	}
+
+	i.Cuts = cl
+	return nil
+}
+
+func (i *Inputs) ParseEffect(s string) error {
+	if i.Effect == nil {
+		i.Effect = NewEffectFilter()
+	}
+
+	switch strings.ToLower(s) {
+	case "reverse":
+		i.Effect.Effect = Reverse
+		return nil
+	case "boomerang":
+		i.Effect.Effect = Boomerang
+		return nil
+	}
+
+	return ErrEffect
+}
+
+// ParsePad parses the padding target, either an aspect
+// ratio as "width:height" or a resolution as "WxH"
+func (i *Inputs) ParsePad(s string) error {
+	if i.Pad == nil {
+		i.Pad = NewPadFilter()
+	}
+
+	if parts := strings.Split(s, "x"); len(parts) == 2 {
+		w, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return ErrPad
+		}
+		h, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return ErrPad
+		}
+
+		i.Pad.Width = w
+		i.Pad.Height = h
+		return nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return ErrPad
+	}
+	w, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || w <= 0 {
+		return ErrPad
+	}
+	h, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || h <= 0 {
+		return ErrPad
+	}
+
+	i.Pad.Aspect = w / h
+	return nil
+}
+
+// ParsePadPosition parses where the video sits within the padding
+// as "x:y", each from 0 (left/top) to 1 (right/bottom)
+func (i *Inputs) ParsePadPosition(s string) error {
+	if i.Pad == nil {
+		i.Pad = NewPadFilter()
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return ErrPad
+	}
+	x, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return ErrPad
+	}
+	y, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return ErrPad
+	}
+
+	i.Pad.X = x
+	i.Pad.Y = y
+	return nil
+}
+
+// ParsePosition parses where the overlay is placed, either an anchor
+// i.e. "top-left/top-right/bottom-left/bottom-right/center" or "x:y"
+func (of *OverlayFilter) ParsePosition(s string) error {
+	switch strings.ToLower(s) {
+	case "top-left":
+		of.Anchor = TopLeft
+	case "top-right":
+		of.Anchor = TopRight
+	case "bottom-left":
+		of.Anchor = BottomLeft
+	case "bottom-right":
+		of.Anchor = BottomRight
+	case "center", "centre":
+		of.Anchor = Centre
+	default:
+		parts := strings.Split(s, ":")
+		if len(parts) != 2 {
+			return ErrOverlay
+		}
+		x, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return ErrOverlay
+		}
+		y, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return ErrOverlay
+		}
+
+		of.Anchor = NoAnchor
+		of.X = x
+		of.Y = y
+	}
+
+	return nil
+}
+
+// ParseMusic parses how the music video is shown i.e.
+// "cover/waves/spectrum/vectorscope", visualisers
+// are drawn at 30fps and the cover image at 1fps
+func (i *Inputs) ParseMusic(s string) error {
+	if i.Music == nil {
+		i.Music = NewMusicVideo()
+	}
+
+	switch strings.ToLower(s) {
+	case "cover":
+		i.Music.Visualiser = NoVisualiser
+		return nil
+	case "waves":
+		i.Music.Visualiser = Waves
+	case "spectrum":
+		i.Music.Visualiser = Spectrum
+	case "vectorscope":
+		i.Music.Visualiser = Vectorscope
+	default:
+		return ErrMusic
+	}
+
+	i.Music.Framerate = 30
+	return nil
+}
+
+// ParseVisualiserSize parses the resolution of the visualiser as "WxH"
+func (i *Inputs) ParseVisualiserSize(s string) error {
+	if i.Music == nil {
+		i.Music = NewMusicVideo()
+	}
+
+	parts := strings.Split(s, "x")
+	if len(parts) != 2 {
+		return ErrMusic
+	}
+	w, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ErrMusic
+	}
+	h, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return ErrMusic
+	}
+
+	i.Music.Width = w
+	i.Music.Height = h
+	return nil
+}
+
+// ParseChannels parses the channels of the output, either "keep/stereo/mono",
+// a channel layout i.e. "5.1" or a pan filter matrix i.e. "stereo|FL<FL+FC|FR<FR+FC"
+func (i *Inputs) ParseChannels(s string) error {
+	if i.Channels == nil {
+		i.Channels = NewChannelFilter()
+	}
+
+	switch strings.ToLower(s) {
+	case "keep":
+		i.Channels.Mode = KeepChannels
+	case "stereo":
+		i.Channels.Mode = Stereo
+	case "mono":
+		i.Channels.Mode = Mono
+	default:
+		if strings.Contains(s, "|") {
+			i.Channels.Mode = CustomMix
+			i.Channels.Matrix = s
+		} else {
+			i.Channels.Mode = CustomLayout
+			i.Channels.Layout = s
+		}
+	}
+
+	if !i.Channels.Valid() {
+		return ErrChannels
+	}
+	return nil
+}
+
+// ParseFormat parses the format of the output i.e. "webm/gif/webp/avif"
+func (i *Inputs) ParseFormat(s string) error {
+	switch strings.ToLower(s) {
+	case "webm":
+		i.Format = WebM
+	case "gif":
+		i.Format = GIF
+	case "webp":
+		i.Format = WebP
+	case "avif":
+		i.Format = AVIF
+	default:
+		return ErrFormat
+	}
+	return nil
+}
+
+// ParseLadder parses the rungs of the DASH ladder as "height:bitrate"
+// pairs separated by commas i.e. "720:1024,480:750", the bitrates
+// are in Kbps. "default" uses the default ladder
+func (i *Inputs) ParseLadder(s string) error {
+	if i.Dash == nil {
+		i.Dash = NewDashLadder()
+	}
+	if strings.ToLower(s) == "default" {
+		return nil
+	}
+
+	rungs := make([]Rung, 0)
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(pair), ":")
+		if len(parts) != 2 {
+			return ErrDash
+		}
+		h, err := strconv.Atoi(strings.TrimSuffix(parts[0], "p"))
+		if err != nil {
+			return ErrDash
+		}
+		b, err := strconv.Atoi(strings.TrimSuffix(parts[1], "k"))
+		if err != nil {
+			return ErrDash
+		}
+		rungs = append(rungs, Rung{Height: h, Bitrate: b})
+	}
+
+	i.Dash.Rungs = rungs
+	i.Dash.Sort()
+	return nil
+}
+
+// ParseVariant parses a variant as its output followed by its options
+// separated by commas i.e. "out.webm,c:v=vp8,crf=10,b:v=1000,scale=720p".
+// The codec defaults to the codec of the main output
+func (i *Inputs) ParseVariant(s string) error {
+	parts := strings.Split(s, ",")
+	v := NewVariant()
+	v.OutputFp = strings.TrimSpace(parts[0])
+	v.Codec = i.Codec
+
+	for _, opt := range parts[1:] {
+		kv := strings.SplitN(strings.TrimSpace(opt), "=", 2)
+		if len(kv) != 2 {
+			return ErrVariant
+		}
+
+		var err error
+		switch kv[0] {
+		case "c:v":
+			v.Codec, err = parseCodec(kv[1])
+		case "crf":
+			v.CRF, err = strconv.Atoi(kv[1])
+		case "b:v":
+			v.Bitrate, err = strconv.Atoi(strings.TrimSuffix(kv[1], "k"))
+		case "scale":
+			v.Resize = NewResizeFilter()
+			if i.Resize != nil {
+				v.Resize.Algorithm = i.Resize.Algorithm
+			}
+			err = v.Resize.ParseResize(kv[1])
+		default:
+			return ErrVariant
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !v.Valid() {
+		return ErrVariant
+	}
+	i.Variants = append(i.Variants, v)
+	return nil
+}
@@ -3,6 +3,8 @@ package ffmpeg
 import (
 	"context"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/vansante/go-ffprobe.v2"
@@ -10,12 +12,16 @@ import (
 
 // FileData holds the data knafeh needs from ffprobe
 type FileData struct {
-	Title           string
-	Width, Height   int
-	DurationSeconds float64
-	VideoStreams    []*ffprobe.Stream
-	AudioStreams    []*ffprobe.Stream
-	SubtitleStreams []*ffprobe.Stream
+	Title             string
+	FormatName        string  // Names of the container i.e. "matroska,webm"
+	Width, Height     int     // Dimensions in display orientation, i.e. after rotation
+	Rotation          int     // Degrees clockwise the video is rotated when displayed
+	SampleAspectRatio float64 // Width of the pixels relative to their height in display orientation
+	Framerate         float64
+	DurationSeconds   float64
+	VideoStreams      []*ffprobe.Stream
+	AudioStreams      []*ffprobe.Stream
+	SubtitleStreams   []*ffprobe.Stream
 }
 
 func (fd *FileData) ValidDimensions() bool {
@@ -52,14 +58,17 @@ func Probe(fp string) (*FileData, error) {
 // probeDataToFileData Converts ffprobe.ProbeData to FileData
 func probeDataToFileData(data *ffprobe.ProbeData) *FileData {
 	var fd = &FileData{
-		Width:           -1,
-		Height:          -1,
-		DurationSeconds: -1,
+		Width:             -1,
+		Height:            -1,
+		SampleAspectRatio: 1,
+		Framerate:         -1,
+		DurationSeconds:   -1,
 	}
 
 	// Get the duration and title
 	if data.Format != nil {
 		fd.DurationSeconds = data.Format.DurationSeconds
+		fd.FormatName = data.Format.FormatName
 
 		if data.Format.Tags != nil {
 			fd.Title = data.Format.Tags.Title
@@ -67,10 +76,18 @@ func probeDataToFileData(data *ffprobe.ProbeData) *FileData {
 
 	}
 
-	// Set width and height
+	// Set width and height, ffmpeg rotates the video when it's decoded
+	// so the dimensions are swapped if it's displayed on its side
 	if data.FirstVideoStream() != nil {
 		fd.Width = data.FirstVideoStream().Width
 		fd.Height = data.FirstVideoStream().Height
+		fd.Rotation = data.FirstVideoStream().Rotation()
+		fd.SampleAspectRatio = parseRatio(data.FirstVideoStream().SampleAspectRatio, ":", 1)
+		if fd.Rotation == 90 || fd.Rotation == 270 {
+			fd.Width, fd.Height = fd.Height, fd.Width
+			fd.SampleAspectRatio = 1 / fd.SampleAspectRatio
+		}
+		fd.Framerate = parseRatio(data.FirstVideoStream().AvgFrameRate, "/", -1)
 	}
 
 	// Retrieve the streams from the probe data
@@ -94,3 +111,24 @@ func probeDataToFileData(data *ffprobe.ProbeData) *FileData {
 
 	return fd
 }
+
+// parseRatio parses a ratio from ffprobe such as a framerate of
+// "30000/1001" or a sample aspect ratio of "1:1", if the ratio
+// is unknown then def is returned
+func parseRatio(r, sep string, def float64) float64 {
+	parts := strings.SplitN(r, sep, 2)
+	if len(parts) != 2 {
+		return def
+	}
+
+	num, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || num == 0 {
+		return def
+	}
+	den, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || den == 0 {
+		return def
+	}
+
+	return num / den
+}
@@ -0,0 +1,256 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/vansante/go-ffprobe.v2"
+)
+
+// Report collects the facts about each encode so they can be written
+// as JSON for a pipeline, several runs of knafeh can be collected into
+// one report by appending their encodes to it
+type Report struct {
+	Encodes []*EncodeReport `json:"encodes"`
+}
+
+// ReadReport reads a report which was written with WriteFile
+func ReadReport(fp string) (*Report, error) {
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// WriteFile writes the report as JSON, the file is replaced
+// at once so a reader never sees part of the report
+func (r *Report) WriteFile(fp string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fp, append(data, '\n'))
+}
+
+// EncodeReport is what was encoded from an input and how it went
+type EncodeReport struct {
+	KnafehVersion string           `json:"knafeh_version"`
+	FFmpegVersion string           `json:"ffmpeg_version"`
+	Settings      interface{}      `json:"settings"` // Resolved Inputs or Thumbnails the commands were built from
+	Input         *ProbeSummary    `json:"input,omitempty"`
+	Commands      []*CommandReport `json:"commands"`
+	Error         string           `json:"error,omitempty"`
+}
+
+// NewEncodeReport returns a report of the encode of the input
+// with the settings, the input is probed for its summary
+func NewEncodeReport(version string, settings interface{}, inputFp string) *EncodeReport {
+	er := &EncodeReport{
+		KnafehVersion: version,
+		FFmpegVersion: "",
+		Settings:      settings,
+		Input:         nil,
+		Commands:      make([]*CommandReport, 0),
+		Error:         "",
+	}
+	er.FFmpegVersion, _ = FFmpegVersion()
+	if fd, err := Probe(inputFp); err == nil {
+		er.Input = summarise(fd)
+	}
+	return er
+}
+
+// Command returns the report of a command which is part of the
+// encode, it's a Reporter so it records each pass of the command
+func (er *EncodeReport) Command(c *Command) *CommandReport {
+	cr := &CommandReport{
+		Output:  c.Output(),
+		Passes:  make([]*PassReport, 0),
+		Outputs: make([]*OutputReport, 0),
+	}
+	er.Commands = append(er.Commands, cr)
+	return cr
+}
+
+// CommandReport is how each pass of a command went and what it output
+type CommandReport struct {
+	mu      sync.Mutex
+	Output  string          `json:"output"`
+	Passes  []*PassReport   `json:"passes"`
+	Outputs []*OutputReport `json:"outputs"`
+}
+
+// PassReport is what ffmpeg was run with for a pass and how long it took
+type PassReport struct {
+	Pass        int      `json:"pass"`
+	Args        []string `json:"args"`
+	WallSeconds float64  `json:"wall_seconds"`
+	CPUSeconds  float64  `json:"cpu_seconds"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// OutputReport describes a file the command wrote
+type OutputReport struct {
+	File         string        `json:"file"`
+	Size         int64         `json:"size"`
+	Bitrate      int           `json:"bitrate_kbps,omitempty"`       // Average bitrate of the whole file
+	VideoBitrate int           `json:"video_bitrate_kbps,omitempty"` // Average bitrate of the video if the container records it
+	AudioBitrate int           `json:"audio_bitrate_kbps,omitempty"` // Average bitrate of the audio if the container records it
+	Probe        *ProbeSummary `json:"probe,omitempty"`
+	Verified     *bool         `json:"verified,omitempty"`
+	Problems     []string      `json:"problems,omitempty"`
+}
+
+func (cr *CommandReport) Report(e Event) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	switch e.Kind {
+	case EventCommand:
+		// The decode of the output while it's verified isn't a pass
+		if e.Pass > 0 {
+			cr.Passes = append(cr.Passes, &PassReport{Pass: e.Pass, Args: e.Args})
+		}
+	case EventPassEnd:
+		for _, p := range cr.Passes {
+			if p.Pass != e.Pass {
+				continue
+			}
+			p.WallSeconds = e.Elapsed.Seconds()
+			p.CPUSeconds = e.CPU.Seconds()
+			if e.Err != nil {
+				p.Error = e.Err.Error()
+			}
+		}
+	case EventVerify:
+		for _, o := range cr.Outputs {
+			if o.File != e.Output {
+				continue
+			}
+			verified := e.Err == nil
+			o.Verified = &verified
+			if ve, ok := e.Err.(*VerifyError); ok {
+				o.Problems = ve.Problems
+			}
+		}
+	}
+}
+
+// AddOutputs records the size and probe summary of each file the
+// command wrote, files which weren't written are left out
+func (cr *CommandReport) AddOutputs(fps []string) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	for _, fp := range fps {
+		fi, err := os.Stat(fp)
+		if err != nil {
+			continue
+		}
+
+		o := &OutputReport{File: fp, Size: fi.Size()}
+		if fd, err := Probe(fp); err == nil {
+			o.Probe = summarise(fd)
+			if fd.ValidDuration() {
+				o.Bitrate = int(float64(fi.Size()) * 8 / fd.DurationSeconds / 1000)
+			}
+			if len(fd.VideoStreams) > 0 {
+				o.VideoBitrate = kbps(fd.VideoStreams[0].BitRate)
+			}
+			if len(fd.AudioStreams) > 0 {
+				o.AudioBitrate = kbps(fd.AudioStreams[0].BitRate)
+			}
+		}
+		cr.Outputs = append(cr.Outputs, o)
+	}
+}
+
+// ProbeSummary is what ffprobe found in a file
+type ProbeSummary struct {
+	Format          string          `json:"format"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	Width           int             `json:"width,omitempty"`
+	Height          int             `json:"height,omitempty"`
+	Framerate       float64         `json:"framerate,omitempty"`
+	Streams         []StreamSummary `json:"streams"`
+}
+
+// StreamSummary is what ffprobe found in a stream
+type StreamSummary struct {
+	Type     string `json:"type"`
+	Codec    string `json:"codec"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Channels int    `json:"channels,omitempty"`
+	Bitrate  int    `json:"bitrate_kbps,omitempty"`
+}
+
+func summarise(fd *FileData) *ProbeSummary {
+	ps := &ProbeSummary{
+		Format:          fd.FormatName,
+		DurationSeconds: fd.DurationSeconds,
+		Streams:         make([]StreamSummary, 0),
+	}
+	if fd.ValidDimensions() {
+		ps.Width, ps.Height = fd.Width, fd.Height
+	}
+	if fd.Framerate > 0 {
+		ps.Framerate = fd.Framerate
+	}
+
+	for _, streams := range [][]*ffprobe.Stream{fd.VideoStreams, fd.AudioStreams, fd.SubtitleStreams} {
+		for _, s := range streams {
+			ps.Streams = append(ps.Streams, StreamSummary{
+				Type:     s.CodecType,
+				Codec:    s.CodecName,
+				Width:    s.Width,
+				Height:   s.Height,
+				Channels: s.Channels,
+				Bitrate:  kbps(s.BitRate),
+			})
+		}
+	}
+
+	return ps
+}
+
+// kbps converts a bitrate from ffprobe in bits per second to
+// kilobits per second, 0 is returned if it isn't known
+func kbps(bitrate string) int {
+	b, err := strconv.Atoi(bitrate)
+	if err != nil {
+		return 0
+	}
+	return b / 1000
+}
+
+// FFmpegVersion returns the version of ffmpeg which is used, e.g. "6.0"
+func FFmpegVersion() (string, error) {
+	out, err := exec.Command("ffmpeg", "-hide_banner", "-version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	// The first line reads "ffmpeg version 6.0 Copyright ..."
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	if scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 2 && fields[1] == "version" {
+			return fields[2], nil
+		}
+	}
+	return "", ErrFFmpegVersion
+}
@@ -0,0 +1,286 @@
+package ffmpeg
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is how important a reported event is
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+func (l Level) String() string {
+	return [...]string{"debug", "info", "warning", "error"}[l]
+}
+
+// ParseLevel parses a level i.e. "debug/info/warning/error"
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return Debug, nil
+	case "info":
+		return Info, nil
+	case "warning", "warn":
+		return Warn, nil
+	case "error":
+		return Error, nil
+	}
+	return Info, ErrLevel
+}
+
+// EventKind is what happened while a command was running
+type EventKind int
+
+const (
+	EventPassStart EventKind = iota // A pass of the command has started
+	EventCommand                    // ffmpeg is run with Args
+	EventProgress                   // ffmpeg has encoded up to Progress
+	EventLog                        // ffmpeg logged Line
+	EventPassEnd                    // A pass has ended after Elapsed, Err is set if it failed
+	EventVerify                     // The output has been verified, Err is set if it failed
+)
+
+func (k EventKind) String() string {
+	return [...]string{"pass_start", "command", "progress", "log", "pass_end", "verify"}[k]
+}
+
+// Event is reported while a command is running
+type Event struct {
+	Kind     EventKind
+	Level    Level
+	Time     time.Time
+	Output   string // Output of the command
+	Pass     int    // Pass which is running, starting from 1
+	Passes   int    // Passes the command runs
+	Args     []string
+	Line     string
+	Elapsed  time.Duration
+	CPU      time.Duration // CPU time ffmpeg used in the pass
+	Err      error
+	Progress *Progress
+}
+
+// Progress is how far through its output ffmpeg is
+type Progress struct {
+	Frame    int
+	FPS      float64
+	Time     time.Duration // Time of the output which has been encoded
+	Duration time.Duration // Duration of the output, -1 if it isn't known
+	Speed    float64       // Speed of the encode relative to playback
+	Size     int64         // Bytes written so far
+}
+
+// Percent returns how far through the output ffmpeg is, -1 if it isn't known
+func (p *Progress) Percent() float64 {
+	if p.Duration <= 0 {
+		return -1
+	}
+	if p.Time >= p.Duration {
+		return 100
+	}
+	return float64(p.Time) / float64(p.Duration) * 100
+}
+
+// Reporter is told about each event while a command is running,
+// commands don't write anything themselves so the reporter decides
+// where everything goes
+type Reporter interface {
+	Report(e Event)
+}
+
+// ReporterFunc lets a function be used as a Reporter
+type ReporterFunc func(e Event)
+
+func (f ReporterFunc) Report(e Event) {
+	f(e)
+}
+
+// NopReporter discards every event
+var NopReporter Reporter = ReporterFunc(func(Event) {})
+
+// MultiReporter returns a reporter which reports
+// each event to every one of the reporters
+func MultiReporter(rs ...Reporter) Reporter {
+	return ReporterFunc(func(e Event) {
+		for _, r := range rs {
+			r.Report(e)
+		}
+	})
+}
+
+// jsonReporter writes each event as a line of JSON
+type jsonReporter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	min Level
+}
+
+// NewJSONReporter returns a reporter which writes the events at or
+// above the min level to w, each event is a line of JSON
+func NewJSONReporter(w io.Writer, min Level) Reporter {
+	return &jsonReporter{w: w, min: min}
+}
+
+func (jr *jsonReporter) Report(e Event) {
+	if e.Level < jr.min {
+		return
+	}
+
+	line := map[string]interface{}{
+		"time":   e.Time.Format(time.RFC3339Nano),
+		"level":  e.Level.String(),
+		"event":  e.Kind.String(),
+		"output": e.Output,
+		"pass":   e.Pass,
+		"passes": e.Passes,
+	}
+	switch e.Kind {
+	case EventCommand:
+		line["args"] = e.Args
+	case EventLog:
+		line["line"] = e.Line
+	case EventProgress:
+		line["frame"] = e.Progress.Frame
+		line["fps"] = e.Progress.FPS
+		line["out_time"] = e.Progress.Time.Seconds()
+		line["speed"] = e.Progress.Speed
+		line["size"] = e.Progress.Size
+		if p := e.Progress.Percent(); p >= 0 {
+			line["percent"] = p
+		}
+	case EventPassEnd:
+		line["elapsed"] = e.Elapsed.Seconds()
+		line["cpu"] = e.CPU.Seconds()
+		if e.Err != nil {
+			line["error"] = e.Err.Error()
+		}
+	case EventVerify:
+		line["ok"] = e.Err == nil
+		if e.Err != nil {
+			line["error"] = e.Err.Error()
+		}
+	}
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jr.w.Write(append(b, '\n'))
+}
+
+// reportArgs are given to ffmpeg so its progress is written to
+// stdout and each line it logs is prefixed with its level
+var reportArgs = []string{"-hide_banner", "-nostats", "-progress", "pipe:1", "-loglevel", "level+info"}
+
+// ffmpegLevels map the level prefixes of ffmpeg's log lines to levels
+var ffmpegLevels = []struct {
+	prefix string
+	level  Level
+}{
+	{"[panic] ", Error},
+	{"[fatal] ", Error},
+	{"[error] ", Error},
+	{"[warning] ", Warn},
+	{"[info] ", Info},
+	{"[verbose] ", Debug},
+	{"[debug] ", Debug},
+	{"[trace] ", Debug},
+}
+
+// parseLogLine returns the level of a line ffmpeg logged and
+// the line without its level prefix
+func parseLogLine(line string) (Level, string) {
+	for _, l := range ffmpegLevels {
+		if n := strings.Index(line, l.prefix); n > -1 {
+			return l.level, line[:n] + line[n+len(l.prefix):]
+		}
+	}
+	return Info, line
+}
+
+// progressParser reads the key=value lines ffmpeg writes
+// with -progress, a block of them ends with "progress="
+type progressParser struct {
+	p    Progress
+	emit func(p Progress)
+}
+
+func (pp *progressParser) line(line string) {
+	kv := strings.SplitN(line, "=", 2)
+	if len(kv) != 2 {
+		return
+	}
+	k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+
+	switch k {
+	case "frame":
+		pp.p.Frame, _ = strconv.Atoi(v)
+	case "fps":
+		pp.p.FPS, _ = strconv.ParseFloat(v, 64)
+	case "total_size":
+		pp.p.Size, _ = strconv.ParseInt(v, 10, 64)
+	case "out_time_us":
+		if us, err := strconv.ParseInt(v, 10, 64); err == nil {
+			pp.p.Time = time.Duration(us) * time.Microsecond
+		}
+	case "speed":
+		pp.p.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(v, "x"), 64)
+	case "progress":
+		pp.emit(pp.p)
+	}
+}
+
+// lineWriter calls fn with each line written to it. ffmpeg
+// redraws its progress with carriage returns so they
+// also end a line
+type lineWriter struct {
+	mu      sync.Mutex
+	partial strings.Builder
+	fn      func(line string)
+}
+
+func newLineWriter(fn func(line string)) *lineWriter {
+	return &lineWriter{fn: fn}
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	for _, b := range p {
+		if b != '\n' && b != '\r' {
+			lw.partial.WriteByte(b)
+			continue
+		}
+		lw.flush()
+	}
+
+	return len(p), nil
+}
+
+// Flush ends the line which is being written
+func (lw *lineWriter) Flush() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.flush()
+}
+
+func (lw *lineWriter) flush() {
+	line := lw.partial.String()
+	lw.partial.Reset()
+	if strings.TrimSpace(line) != "" {
+		lw.fn(line)
+	}
+}
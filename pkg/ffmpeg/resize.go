@@ -0,0 +1,187 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+)
+
+// ResizeMode is how the ResizeFilter fits the video into its target
+type ResizeMode int
+
+const (
+	Exact     ResizeMode = iota // Resize to Width by Height, a negative dimension keeps the aspect ratio
+	Fit                         // Fit within Width by Height, keeping the aspect ratio
+	Fill                        // Fill Width by Height, keeping the aspect ratio and cropping the overflow
+	LongEdge                    // Resize so the longest edge is Width, keeping the aspect ratio
+	ShortEdge                   // Resize so the shortest edge is Width, keeping the aspect ratio
+	Percent                     // Resize by Percent, keeping the aspect ratio
+)
+
+// MaxDimension is the largest width or height the resized video can have
+const MaxDimension = 16384
+
+// scaleAlgorithms are the scaling algorithms ffmpeg's scale filter accepts
+var scaleAlgorithms = []string{
+	"fast_bilinear", "bilinear", "bicubic", "experimental", "neighbor",
+	"area", "bicublin", "gauss", "sinc", "lanczos", "spline",
+}
+
+// namedResolutions are the short edges of the named resolutions, i.e. 720p
+var namedResolutions = map[string]int{
+	"240p":  240,
+	"360p":  360,
+	"480p":  480,
+	"720p":  720,
+	"1080p": 1080,
+	"1440p": 1440,
+	"2160p": 2160,
+	"4k":    2160,
+}
+
+// ResizeFilter resizes a video into a new width and height, the
+// resized dimensions are always even and non-square pixels are
+// corrected so the output has square pixels
+type ResizeFilter struct {
+	Mode          ResizeMode
+	Width, Height int     // Width and Height in pixels
+	Percent       float64 // Percentage to resize by when using the Percent mode
+	Algorithm     string  // Scaling algorithm i.e. lanczos, bicubic or spline
+}
+
+func NewResizeFilter() *ResizeFilter {
+	return &ResizeFilter{
+		Mode:      Exact,
+		Width:     0,
+		Height:    0,
+		Percent:   100,
+		Algorithm: "lanczos",
+	}
+}
+
+func (rf *ResizeFilter) ValidResolution() bool {
+	if !rf.ValidAlgorithm() {
+		return false
+	}
+
+	switch rf.Mode {
+	case Exact:
+		// We allow -1 etc. to keep the aspect ratio
+		return rf.Width != 0 && rf.Height != 0 && (rf.Width > 0 || rf.Height > 0)
+	case Fit, Fill:
+		return rf.Width > 0 && rf.Height > 0
+	case LongEdge, ShortEdge:
+		return rf.Width > 0
+	case Percent:
+		return rf.Percent > 0
+	}
+
+	return false
+}
+
+func (rf *ResizeFilter) ValidAlgorithm() bool {
+	for _, a := range scaleAlgorithms {
+		if rf.Algorithm == a {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsSource returns whether the dimensions of the source
+// video are needed to work out the resized dimensions
+func (rf *ResizeFilter) NeedsSource() bool {
+	return !(rf.Mode == Exact && rf.Width > 0 && rf.Height > 0)
+}
+
+// ValidDimensions returns whether a video of srcW by srcH with
+// the sample aspect ratio sar can be resized by the filter
+func (rf *ResizeFilter) ValidDimensions(srcW, srcH int, sar float64) bool {
+	if !rf.ValidResolution() {
+		return false
+	}
+	if rf.NeedsSource() && (srcW <= 0 || srcH <= 0) {
+		return false
+	}
+
+	w, h := rf.Dimensions(srcW, srcH, sar)
+	return w >= 2 && h >= 2 && w <= MaxDimension && h <= MaxDimension
+}
+
+// Dimensions returns the width and height a video of srcW by srcH
+// with the sample aspect ratio sar is resized to
+func (rf *ResizeFilter) Dimensions(srcW, srcH int, sar float64) (int, int) {
+	if srcW <= 0 || srcH <= 0 {
+		return even(float64(rf.Width)), even(float64(rf.Height))
+	}
+
+	// Work with the displayed width so non-square pixels are corrected
+	sw, sh := float64(srcW), float64(srcH)
+	if sar > 0 {
+		sw *= sar
+	}
+
+	var w, h float64
+	switch rf.Mode {
+	case Exact:
+		w, h = float64(rf.Width), float64(rf.Height)
+		if w < 0 {
+			w = float64(roundTo(h*sw/sh, -rf.Width))
+		}
+		if h < 0 {
+			h = float64(roundTo(w*sh/sw, -rf.Height))
+		}
+	case Fit:
+		s := math.Min(float64(rf.Width)/sw, float64(rf.Height)/sh)
+		w, h = sw*s, sh*s
+	case Fill:
+		w, h = float64(rf.Width), float64(rf.Height)
+	case LongEdge:
+		s := float64(rf.Width) / math.Max(sw, sh)
+		w, h = sw*s, sh*s
+	case ShortEdge:
+		s := float64(rf.Width) / math.Min(sw, sh)
+		w, h = sw*s, sh*s
+	case Percent:
+		w, h = sw*rf.Percent/100, sh*rf.Percent/100
+	}
+
+	return even(w), even(h)
+}
+
+// Args returns the filters which resize a video of srcW
+// by srcH with the sample aspect ratio sar
+func (rf *ResizeFilter) Args(srcW, srcH int, sar float64) [][]string {
+	w, h := rf.Dimensions(srcW, srcH, sar)
+
+	args := make([][]string, 0)
+	if rf.Mode == Fill && srcW > 0 && srcH > 0 {
+		// Scale the video until it covers the target then crop the overflow
+		sw, sh := float64(srcW), float64(srcH)
+		if sar > 0 {
+			sw *= sar
+		}
+		s := math.Max(float64(w)/sw, float64(h)/sh)
+		cw, ch := int(math.Max(float64(w), float64(even(sw*s)))), int(math.Max(float64(h), float64(even(sh*s))))
+
+		args = append(args, []string{"scale", fmt.Sprintf("%d:%d:flags=%s", cw, ch, rf.Algorithm)})
+		args = append(args, []string{"crop", fmt.Sprintf("%d:%d", w, h)})
+	} else {
+		args = append(args, []string{"scale", fmt.Sprintf("%d:%d:flags=%s", w, h, rf.Algorithm)})
+	}
+	args = append(args, []string{"setsar", "1"})
+
+	return args
+}
+
+// even rounds f to the nearest even number
+func even(f float64) int {
+	return roundTo(f, 2)
+}
+
+// roundTo rounds f to the nearest multiple of n
+func roundTo(f float64, n int) int {
+	if n <= 1 {
+		return int(math.Round(f))
+	}
+	return int(math.Round(f/float64(n))) * n
+}
@@ -0,0 +1,235 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BestFrame is the poster time which picks the most representative frame
+const BestFrame = "best"
+
+// How many frames are sampled from across the video to pick the best frame
+const bestFrameSamples = 100
+
+// Thumbnails generates images of a video: a poster frame, a contact
+// sheet of frames from across the video with their timestamps and
+// a sprite sheet with a WebVTT track which web players use to
+// preview where they're seeking to
+type Thumbnails struct {
+	InputFp       string
+	Duration      float64 // Duration of the input in seconds
+	Width, Height int     // Dimensions of the input in display orientation
+
+	Poster   string // When the poster frame is taken, accepts "HH:MM:SS.MS/HH:MM:SS/S" or "best"
+	PosterFp string // Filepath of the poster, empty if it isn't made
+
+	SheetFp       string // Filepath of the contact sheet, empty if it isn't made
+	Columns, Rows int    // Cells in the contact sheet
+	CellWidth     int    // Width of each cell in the contact sheet
+	FontFile      string // Font the timestamps are drawn with
+
+	SpriteFp       string  // Filepath of the sprite sheet, the WebVTT track is written next to it
+	SpriteInterval float64 // Seconds between each frame in the sprite sheet
+	SpriteColumns  int     // Frames in each row of the sprite sheet
+	SpriteWidth    int     // Width of each frame in the sprite sheet
+}
+
+func NewThumbnails() *Thumbnails {
+	return &Thumbnails{
+		InputFp:        "",
+		Duration:       -1,
+		Width:          -1,
+		Height:         -1,
+		Poster:         BestFrame,
+		PosterFp:       "",
+		SheetFp:        "",
+		Columns:        4,
+		Rows:           4,
+		CellWidth:      320,
+		FontFile:       "",
+		SpriteFp:       "",
+		SpriteInterval: 5,
+		SpriteColumns:  10,
+		SpriteWidth:    160,
+	}
+}
+
+func (t *Thumbnails) Valid() error {
+	if t.InputFp == "" || t.Duration <= 0 || t.Width <= 0 || t.Height <= 0 {
+		return ErrThumbsInput
+	}
+	if t.PosterFp == "" && t.SheetFp == "" && t.SpriteFp == "" {
+		return ErrThumbsNone
+	}
+	if t.PosterFp != "" && t.Poster != BestFrame {
+		if _, err := parseTrimTime(t.Poster); err != nil {
+			return ErrThumbs
+		}
+	}
+	if t.SheetFp != "" && (t.Columns < 1 || t.Rows < 1 || t.CellWidth < 2) {
+		return ErrThumbs
+	}
+	if t.SpriteFp != "" && (t.SpriteInterval <= 0 || t.SpriteColumns < 1 || t.SpriteWidth < 2) {
+		return ErrThumbs
+	}
+	return nil
+}
+
+// Commands returns a command for each image which is made
+func (t *Thumbnails) Commands() ([]*Command, error) {
+	if err := t.Valid(); err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*Command, 0, 3)
+	if t.PosterFp != "" {
+		cmds = append(cmds, t.posterCommand())
+	}
+	if t.SheetFp != "" {
+		cmds = append(cmds, t.sheetCommand())
+	}
+	if t.SpriteFp != "" {
+		cmds = append(cmds, t.spriteCommand())
+	}
+
+	return cmds, nil
+}
+
+// imageCommand returns a command which writes a single image
+func (t *Thumbnails) imageCommand(outputFp string) *Command {
+	c := newCommand()
+	c.addInput(t.InputFp)
+	c.outputFp = outputFp
+	c.setVideoSource("0:v:0")
+	c.addGeneralArg("-frames:v", "1")
+	c.addGeneralArg("-update", "1")
+	c.addGeneralArg("-q:v", "2")
+
+	// The image's dimensions depend on the thumbnail so
+	// it's only checked that it holds a frame
+	c.expect = NewExpectation()
+	c.expect.VideoStreams = 1
+	c.expect.AudioStreams = 0
+	return c
+}
+
+func (t *Thumbnails) posterCommand() *Command {
+	c := t.imageCommand(t.PosterFp)
+
+	// The best frame is picked from frames sampled evenly across the
+	// video, otherwise the input is seeked straight to the poster
+	if t.Poster == BestFrame {
+		c.addVideoFilterArg("fps", fmt.Sprintf("%d/%s", bestFrameSamples, strconv.FormatFloat(t.Duration, 'f', -1, 64)))
+		c.addVideoFilterArg("thumbnail", fmt.Sprintf("n=%d", bestFrameSamples))
+	} else {
+		c.addInputSeekArg("-ss", t.Poster)
+	}
+
+	return c
+}
+
+// cellHeight returns the height of a frame scaled to the width
+func (t *Thumbnails) cellHeight(width int) int {
+	return int(math.Max(2, float64(even(float64(width)*float64(t.Height)/float64(t.Width)))))
+}
+
+func (t *Thumbnails) sheetCommand() *Command {
+	c := t.imageCommand(t.SheetFp)
+
+	cells := t.Columns * t.Rows
+	h := t.cellHeight(t.CellWidth)
+
+	// Each cell is labelled with the time of its frame
+	timestamp := []string{
+		"text=" + escapeFilterArg("%{pts:hms}"),
+		"x=4",
+		"y=h-th-4",
+		fmt.Sprintf("fontsize=%d", int(math.Max(8, float64(h)/10))),
+		"fontcolor=white",
+		"box=1",
+		"boxcolor=" + escapeFilterArg("black@0.6"),
+		"boxborderw=2",
+	}
+	if t.FontFile != "" {
+		timestamp = append(timestamp, "fontfile="+escapeFilterArg(t.FontFile))
+	}
+
+	c.addVideoFilterArg("fps", fmt.Sprintf("%d/%s", cells, strconv.FormatFloat(t.Duration, 'f', -1, 64)))
+	c.addVideoFilterArg("scale", fmt.Sprintf("%d:%d:flags=lanczos", t.CellWidth, h))
+	c.addVideoFilterArg("setsar", "1")
+	c.addVideoFilterArg("drawtext", strings.Join(timestamp, ":"))
+	c.addVideoFilterArg("tile", fmt.Sprintf("%dx%d:padding=2:margin=2", t.Columns, t.Rows))
+
+	return c
+}
+
+// spriteFrames returns how many frames are in the sprite sheet
+func (t *Thumbnails) spriteFrames() int {
+	return int(math.Ceil(t.Duration / t.SpriteInterval))
+}
+
+func (t *Thumbnails) spriteRows() int {
+	return int(math.Ceil(float64(t.spriteFrames()) / float64(t.SpriteColumns)))
+}
+
+func (t *Thumbnails) spriteCommand() *Command {
+	c := t.imageCommand(t.SpriteFp)
+
+	h := t.cellHeight(t.SpriteWidth)
+	c.addVideoFilterArg("fps", "1/"+strconv.FormatFloat(t.SpriteInterval, 'f', -1, 64))
+	c.addVideoFilterArg("scale", fmt.Sprintf("%d:%d:flags=lanczos", t.SpriteWidth, h))
+	c.addVideoFilterArg("setsar", "1")
+	c.addVideoFilterArg("tile", fmt.Sprintf("%dx%d", t.SpriteColumns, t.spriteRows()))
+
+	// The track is written with the sprite sheet so
+	// it's checked before overwriting like the sheet
+	c.sidecars = append(c.sidecars, sidecar{fp: t.VTTFp(), data: []byte(t.VTT())})
+
+	return c
+}
+
+// VTTFp returns the filepath of the WebVTT track for the sprite sheet
+func (t *Thumbnails) VTTFp() string {
+	return strings.TrimSuffix(t.SpriteFp, filepath.Ext(t.SpriteFp)) + ".vtt"
+}
+
+// VTT returns the WebVTT track which points each interval of
+// the video at its frame in the sprite sheet
+func (t *Thumbnails) VTT() string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+
+	w, h := t.SpriteWidth, t.cellHeight(t.SpriteWidth)
+	sprite := filepath.Base(t.SpriteFp)
+	for n := 0; n < t.spriteFrames(); n++ {
+		start := float64(n) * t.SpriteInterval
+		end := math.Min(start+t.SpriteInterval, t.Duration)
+		x, y := (n%t.SpriteColumns)*w, (n/t.SpriteColumns)*h
+
+		fmt.Fprintf(&b, "\n%s --> %s\n", vttTime(start), vttTime(end))
+		fmt.Fprintf(&b, "%s#xywh=%d,%d,%d,%d\n", sprite, x, y, w, h)
+	}
+
+	return b.String()
+}
+
+// vttTime formats seconds as a WebVTT timestamp i.e. "00:01:05.500"
+func vttTime(s float64) string {
+	d := time.Duration(math.Round(s*1000)) * time.Millisecond
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	sec := d / time.Second
+	d -= sec * time.Second
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, sec, d/time.Millisecond)
+}
+
+// PosterFp returns the filepath of the poster which is written next to the output
+func PosterFp(outputFp string) string {
+	return strings.TrimSuffix(outputFp, filepath.Ext(outputFp)) + ".jpg"
+}
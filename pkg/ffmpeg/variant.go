@@ -0,0 +1,109 @@
+package ffmpeg
+
+// Variant is another output encoded alongside the main output. It's
+// encoded in the same ffmpeg process so the input is only decoded
+// and filtered once, then the filtered video is split between the
+// main output and each variant. A variant can have its own codec,
+// quality and scale, which resizes the main output's frames so it
+// shouldn't be larger than the main output
+type Variant struct {
+	OutputFp string
+	Codec    Codec
+	CRF      int           // CRF of the video, -1 uses the main output's CRF
+	Bitrate  int           // Most bitrate of the video in Kbps, 0 lets the CRF decide it
+	Resize   *ResizeFilter // Resizes the video, nil keeps the main output's dimensions
+}
+
+func NewVariant() *Variant {
+	return &Variant{
+		OutputFp: "",
+		Codec:    VP9,
+		CRF:      -1,
+		Bitrate:  0,
+		Resize:   nil,
+	}
+}
+
+func (v *Variant) Valid() bool {
+	if v.OutputFp == "" || v.CRF < -1 || v.CRF > 63 || v.Bitrate < 0 {
+		return false
+	}
+	return v.Resize == nil || v.Resize.ValidResolution()
+}
+
+func (i *Inputs) preprocessVariants() error {
+	if i.Format != WebM || i.AudioOnly || i.Dash != nil {
+		return ErrVariantOutput
+	}
+	for _, v := range i.Variants {
+		if !v.Valid() {
+			return ErrVariant
+		}
+		if v.OutputFp == i.OutputFp {
+			return ErrVariantOutput
+		}
+	}
+	return nil
+}
+
+// processVariants splits the filtered streams between the main output
+// and the variants, then each variant is encoded like the main output
+func (i *Inputs) processVariants() error {
+	if len(i.Variants) == 0 {
+		return nil
+	}
+
+	n := len(i.Variants) + 1
+	videos := i.c.splitStream(i.c.video, "split", "v", n)
+	audios := i.c.splitStream(i.c.audio, "asplit", "a", n)
+	i.c.video, i.c.audio = videos[0], audios[0]
+
+	// The variants are resized from the main output's frames
+	w, h := i.OutputDimensions()
+
+	for n, v := range i.Variants {
+		vi := i.clone()
+		vi.Variants = nil
+		vi.OutputFp = v.OutputFp
+		vi.Codec = v.Codec
+		vi.VarArgs.codec = v.Codec
+		vi.VarArgs.Bitrate = v.Bitrate
+		if v.CRF > -1 {
+			vi.VarArgs.CRF = v.CRF
+		}
+		vi.Width, vi.Height, vi.SampleAspectRatio = w, h, 1
+		vi.Crop, vi.Rotate, vi.Pad = nil, nil, nil
+		vi.Resize = v.Resize
+		if vi.Resize != nil && !vi.Resize.ValidDimensions(w, h, 1) {
+			return ErrResize
+		}
+		if valid, err := vi.VarArgs.Valid(); !valid {
+			return err
+		}
+
+		vi.c = newCommand()
+		vi.c.twoPass = i.c.twoPass
+		vi.c.duration = i.c.duration
+		vi.c.outputFp = v.OutputFp
+		vi.c.video, vi.c.audio = videos[n+1], audios[n+1]
+		for pair := i.c.generalArgs.Oldest(); pair != nil; pair = pair.Next() {
+			vi.c.addGeneralArg(pair.Key.(string), pair.Value.(string))
+		}
+
+		vi.processResize()
+		vi.processVideoArgs()
+		vi.processAudioCodec()
+		vi.c.expect = vi.expectation()
+
+		// The variant's filters are part of the main output's filtergraph
+		if vi.c.hasVideo() {
+			i.c.closeStream(vi.c.video, "v")
+		}
+		if vi.c.hasAudio() {
+			i.c.closeStream(vi.c.audio, "a")
+		}
+		i.c.outputs = append(i.c.outputs, vi.c)
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// How far the duration of an output may be from what's expected,
+// trimming on a frame and the audio's padding both shift it a little
+const (
+	durationTolerance = 500 * time.Millisecond
+	durationDeviation = 0.02 // Fraction of the expected duration it may also be out by
+)
+
+// probeFormats map the muxers knafeh writes with to
+// the names ffprobe reports the containers as
+var probeFormats = map[string][]string{
+	"webm": {"webm", "matroska"},
+	"gif":  {"gif"},
+	"webp": {"webp_pipe", "webp"},
+	"avif": {"mov", "mp4", "avif"},
+	"opus": {"ogg"},
+	"ogg":  {"ogg"},
+}
+
+// probeCodecs map the encoders knafeh uses to the
+// names ffprobe reports the codecs as
+var probeCodecs = map[string]string{
+	"libvpx":       "vp8",
+	"libvpx-vp9":   "vp9",
+	"libaom-av1":   "av1",
+	"gif":          "gif",
+	"libwebp":      "webp",
+	"libwebp_anim": "webp",
+	"libopus":      "opus",
+	"libvorbis":    "vorbis",
+}
+
+// Expectation is what an output should contain once it's
+// been encoded, the values which aren't known aren't checked
+type Expectation struct {
+	Format        string        // Muxer the output is written with, "" if it isn't known
+	VideoCodec    string        // Encoder of the video, "" if it isn't known
+	AudioCodec    string        // Encoder of the audio, "" if it isn't known
+	VideoStreams  int           // -1 if it isn't known
+	AudioStreams  int           // -1 if it isn't known
+	Width, Height int           // Dimensions of the video, -1 if they aren't known
+	Duration      time.Duration // Duration after trimming, speeding and looping, -1 if it isn't known
+}
+
+func NewExpectation() *Expectation {
+	return &Expectation{
+		Format:       "",
+		VideoCodec:   "",
+		AudioCodec:   "",
+		VideoStreams: -1,
+		AudioStreams: -1,
+		Width:        -1,
+		Height:       -1,
+		Duration:     -1,
+	}
+}
+
+// Check returns the ways the probed output differs from the expectation
+func (e *Expectation) Check(fd *FileData) []string {
+	problems := make([]string, 0)
+
+	if names, ok := probeFormats[e.Format]; ok && !matchesFormat(fd.FormatName, names) {
+		problems = append(problems, fmt.Sprintf("container is %q, expected %s", fd.FormatName, e.Format))
+	}
+
+	if e.VideoStreams > -1 && len(fd.VideoStreams) != e.VideoStreams {
+		problems = append(problems, fmt.Sprintf("has %d video streams, expected %d", len(fd.VideoStreams), e.VideoStreams))
+	}
+	if e.AudioStreams > -1 && len(fd.AudioStreams) != e.AudioStreams {
+		problems = append(problems, fmt.Sprintf("has %d audio streams, expected %d", len(fd.AudioStreams), e.AudioStreams))
+	}
+
+	if codec, ok := probeCodecs[e.VideoCodec]; ok && len(fd.VideoStreams) > 0 && fd.VideoStreams[0].CodecName != codec {
+		problems = append(problems, fmt.Sprintf("video codec is %s, expected %s", fd.VideoStreams[0].CodecName, codec))
+	}
+	if codec, ok := probeCodecs[e.AudioCodec]; ok && len(fd.AudioStreams) > 0 && fd.AudioStreams[0].CodecName != codec {
+		problems = append(problems, fmt.Sprintf("audio codec is %s, expected %s", fd.AudioStreams[0].CodecName, codec))
+	}
+
+	// Resizing rounds the dimensions to what the encoder can use
+	if e.Width > 0 && e.Height > 0 && (abs(fd.Width-e.Width) > 2 || abs(fd.Height-e.Height) > 2) {
+		problems = append(problems, fmt.Sprintf("dimensions are %dx%d, expected %dx%d", fd.Width, fd.Height, e.Width, e.Height))
+	}
+
+	if e.Duration > 0 {
+		d := time.Duration(fd.DurationSeconds * float64(time.Second))
+		tolerance := durationTolerance + time.Duration(float64(e.Duration)*durationDeviation)
+		if !fd.ValidDuration() || math.Abs(float64(d-e.Duration)) > float64(tolerance) {
+			problems = append(problems, fmt.Sprintf("duration is %ss, expected %ss", formatSeconds(d), formatSeconds(e.Duration)))
+		}
+	}
+
+	return problems
+}
+
+// matchesFormat returns whether one of ffprobe's names
+// for a container is one of the expected names
+func matchesFormat(formatName string, names []string) bool {
+	for _, f := range strings.Split(formatName, ",") {
+		for _, n := range names {
+			if f == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyError is returned when an output isn't what the command was
+// asked to encode, errors.Is(err, ErrVerify) matches every VerifyError
+type VerifyError struct {
+	Output   string
+	Problems []string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%s: %v: %s", e.Output, ErrVerify, strings.Join(e.Problems, "; "))
+}
+
+func (e *VerifyError) Is(target error) bool {
+	return target == ErrVerify
+}
+
+// expectation returns what the output of the command should contain,
+// it's called once the command is built so the streams are known
+func (i *Inputs) expectation() *Expectation {
+	e := NewExpectation()
+	e.Format = i.format()
+	e.VideoCodec = argValue(i.c.videoCodecArgs.Get("-c:v"))
+	e.AudioCodec = argValue(i.c.audioCodecArgs.Get("-c:a"))
+
+	e.VideoStreams, e.AudioStreams = 0, 0
+	if i.c.hasVideo() {
+		e.VideoStreams = 1
+	}
+	if i.c.mapsAudio(false) {
+		e.AudioStreams = 1
+	}
+
+	// The cover's dimensions are only rounded to be even
+	if i.c.hasVideo() && !(i.stillMusic() && i.Music.CoverFp != "") {
+		e.Width, e.Height = i.OutputDimensions()
+	}
+	if d, err := i.OutputDuration(); err == nil && d > 0 {
+		e.Duration = d
+	}
+
+	return e
+}
+
+func argValue(v interface{}, ok bool) string {
+	if s, isString := v.(string); ok && isString {
+		return s
+	}
+	return ""
+}
+
+// Verify checks the outputs are what the command was asked to encode,
+// each output is probed and compared to its expectation then decoded
+// to find any corrupt frames, WebP outputs are only probed. The result
+// is reported for each output and the first output which fails is
+// returned as a VerifyError
+func (c *Command) Verify() error {
+	for _, o := range append([]*Command{c}, c.outputs...) {
+		if o.expect == nil {
+			continue
+		}
+
+		err := o.verify()
+		level := Info
+		if err != nil {
+			level = Error
+		}
+		o.report(Event{Kind: EventVerify, Level: level, Err: err})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Command) verify() error {
+	fd, err := Probe(c.outputFp)
+	if err != nil {
+		return &VerifyError{Output: c.outputFp, Problems: []string{fmt.Sprintf("can't be probed: %v", err)}}
+	}
+
+	// Older builds of ffmpeg can't decode animated WebP
+	// so only the probe of a WebP output is checked
+	problems := c.expect.Check(fd)
+	if len(problems) == 0 && c.expect.Format != "webp" {
+		problems = append(problems, c.decodeErrors()...)
+	}
+	if len(problems) > 0 {
+		return &VerifyError{Output: c.outputFp, Problems: problems}
+	}
+	return nil
+}
+
+// decodeErrors decodes every stream of the output without writing
+// anything and returns the errors ffmpeg found while decoding
+func (c *Command) decodeErrors() []string {
+	args := []string{"-hide_banner", "-nostats", "-loglevel", "level+error", "-i", c.outputFp, "-map", "0", "-f", "null", nullOutput()}
+	c.report(Event{Kind: EventCommand, Level: Debug, Args: append([]string{"ffmpeg"}, args...)})
+
+	stderr := newStderrBuffer(stderrLines)
+	logs := newLineWriter(func(line string) {
+		if level, line := parseLogLine(line); level >= Error {
+			stderr.push("decoding: " + line)
+		}
+	})
+
+	p := exec.Command("ffmpeg", args...)
+	p.Stderr = logs
+	err := p.Run()
+	logs.Flush()
+
+	errs := stderr.Lines()
+	if err != nil && len(errs) == 0 {
+		errs = append(errs, fmt.Sprintf("decoding: %v", err))
+	}
+	return errs
+}
@@ -86,6 +86,7 @@ type Stream struct {
 	Channels           int               `json:"channels,omitempty"`
 	ChannelLayout      string            `json:"channel_layout,omitempty"`
 	BitsPerSample      int               `json:"bits_per_sample,omitempty"`
+	SideDataList       []StreamSideData  `json:"side_data_list,omitempty"`
 }
 
 // StreamDisposition is a json data structure to represent stream dispositions
@@ -103,6 +104,13 @@ type StreamDisposition struct {
 	AttachedPic     int `json:"attached_pic"`
 }
 
+// StreamSideData is a json data structure to represent stream side data
+type StreamSideData struct {
+	SideDataType  string `json:"side_data_type"`
+	DisplayMatrix string `json:"displaymatrix,omitempty"`
+	Rotation      int    `json:"rotation,omitempty"`
+}
+
 // StreamTags is a json data structure to represent stream tags
 type StreamTags struct {
 	Rotate       int    `json:"rotate,string,omitempty"`
@@ -123,6 +131,19 @@ func (f *Format) Duration() (duration time.Duration) {
 	return time.Duration(f.DurationSeconds * float64(time.Second))
 }
 
+// Rotation returns how many degrees clockwise the stream should be rotated
+// to be displayed, it's read from the display matrix side data if there is
+// any and otherwise from the older rotate tag
+func (s *Stream) Rotation() int {
+	for _, sd := range s.SideDataList {
+		if sd.SideDataType == "Display Matrix" {
+			// The display matrix rotation is counter-clockwise
+			return ((-sd.Rotation % 360) + 360) % 360
+		}
+	}
+	return ((s.Tags.Rotate % 360) + 360) % 360
+}
+
 // StreamType returns all streams which are of the given type
 func (p *ProbeData) StreamType(streamType StreamType) (streams []Stream) {
 	for _, s := range p.Streams {
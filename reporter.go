@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiwippi/knafeh/pkg/ffmpeg"
+)
+
+// cliReporter renders the events of the commands for a
+// terminal, the progress is redrawn on a single line
+type cliReporter struct {
+	mu          sync.Mutex
+	w           io.Writer
+	min         ffmpeg.Level
+	progressing bool
+}
+
+func (cr *cliReporter) Report(e ffmpeg.Event) {
+	if e.Level < cr.min {
+		return
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	// The progress line is ended before anything else is written
+	if cr.progressing && e.Kind != ffmpeg.EventProgress {
+		fmt.Fprintln(cr.w)
+		cr.progressing = false
+	}
+
+	switch e.Kind {
+	case ffmpeg.EventPassStart:
+		fmt.Fprintf(cr.w, "pass %d/%d: %s\n", e.Pass, e.Passes, e.Output)
+	case ffmpeg.EventCommand:
+		fmt.Fprintln(cr.w, strings.Join(e.Args, " "))
+	case ffmpeg.EventLog:
+		if e.Level >= ffmpeg.Warn {
+			fmt.Fprintf(cr.w, "%s: %s\n", e.Level, e.Line)
+		} else {
+			fmt.Fprintln(cr.w, e.Line)
+		}
+	case ffmpeg.EventProgress:
+		fmt.Fprintf(cr.w, "\r%s", progressLine(e.Progress))
+		cr.progressing = true
+	case ffmpeg.EventPassEnd:
+		if e.Err != nil {
+			fmt.Fprintf(cr.w, "pass %d/%d failed after %s\n", e.Pass, e.Passes, e.Elapsed.Round(time.Millisecond))
+		} else {
+			fmt.Fprintf(cr.w, "pass %d/%d done in %s\n", e.Pass, e.Passes, e.Elapsed.Round(time.Millisecond))
+		}
+	case ffmpeg.EventVerify:
+		if e.Err != nil {
+			fmt.Fprintf(cr.w, "verification failed: %v\n", e.Err)
+		} else {
+			fmt.Fprintf(cr.w, "verified %s\n", e.Output)
+		}
+	}
+}
+
+func progressLine(p *ffmpeg.Progress) string {
+	line := fmt.Sprintf("frame=%d fps=%.1f time=%s speed=%.2fx size=%dKiB",
+		p.Frame, p.FPS, p.Time.Round(time.Second), p.Speed, p.Size/1024)
+	if pc := p.Percent(); pc >= 0 {
+		line += fmt.Sprintf(" %.1f%%", pc)
+	}
+	return line
+}